@@ -0,0 +1,187 @@
+package fortio
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// listFlag is the command line flag of a typed slice or map field. It holds
+// the comma separated value given on the command line, repeated flags add to
+// it, and the value is parsed into the field by CmdLineConfigLoader.
+type listFlag struct {
+	value   string
+	typ     string
+	changed bool
+}
+
+func (l *listFlag) String() string { return l.value }
+
+func (l *listFlag) Set(s string) error {
+	if l.changed && s != "" {
+		l.value += "," + s
+	} else {
+		l.value = s
+	}
+	l.changed = true
+	return nil
+}
+
+func (l *listFlag) Type() string { return l.typ }
+
+// isCollection reports whether addr points to a slice or map field that is
+// loaded element by element rather than through pflag.Value
+func isCollection(addr interface{}) bool {
+	if _, ok := addr.(pflag.Value); ok || textParser(addr) != nil {
+		return false
+	}
+	switch reflect.TypeOf(addr).Elem().Kind() {
+	case reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// setCollection parses raw into the slice or map dest points to. raw is either
+// a comma separated string like "1,2" or "k=v,k2=v2" from a flag or env
+// variable, or a list or map read from a config file.
+func setCollection(dest reflect.Value, raw interface{}) error {
+	t := dest.Elem().Type()
+	if t.Kind() == reflect.Map {
+		entries, err := mapEntries(raw)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(t, len(entries))
+		for k, v := range entries {
+			key, err := parseElem(t.Key(), k)
+			if err != nil {
+				return err
+			}
+			value, err := parseElem(t.Elem(), v)
+			if err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		dest.Elem().Set(m)
+		return nil
+	}
+
+	var items []string
+	switch v := raw.(type) {
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+	default:
+		for _, item := range strings.Split(fmt.Sprint(v), ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	s := reflect.MakeSlice(t, 0, len(items))
+	for _, item := range items {
+		value, err := parseElem(t.Elem(), item)
+		if err != nil {
+			return err
+		}
+		s = reflect.Append(s, value)
+	}
+	dest.Elem().Set(s)
+	return nil
+}
+
+// mapEntries returns the entries of a map read from a config file, or of a
+// comma separated list of key=value pairs
+func mapEntries(raw interface{}) (map[string]string, error) {
+	entries := map[string]string{}
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			entries[k] = fmt.Sprint(value)
+		}
+	case map[interface{}]interface{}:
+		for k, value := range v {
+			entries[fmt.Sprint(k)] = fmt.Sprint(value)
+		}
+	default:
+		for _, pair := range strings.Split(fmt.Sprint(v), ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("expecting key=value, got %q", pair)
+			}
+			entries[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return entries, nil
+}
+
+// parseElem parses s into a new value of type t, a scalar kind or a type
+// parsing itself from text like Duration or net.IP
+func parseElem(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t)
+	if parse := textParser(v.Interface()); parse != nil {
+		err := parse(s)
+		return v.Elem(), err
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		v.Elem().SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.Elem().SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, t.Bits())
+		v.Elem().SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 10, t.Bits())
+		v.Elem().SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, t.Bits())
+		v.Elem().SetFloat(f)
+	default:
+		return v.Elem(), fmt.Errorf("unsupported element type: %s", t)
+	}
+	if err != nil {
+		return v.Elem(), fmt.Errorf("%q is not a %s", s, t)
+	}
+	return v.Elem(), nil
+}
+
+// formatCollection returns a slice or map as it would be given on the command
+// line, like "1,2" or "k=v,k2=v2"
+func formatCollection(v reflect.Value) string {
+	var items []string
+	if v.Kind() == reflect.Map {
+		for _, k := range v.MapKeys() {
+			items = append(items, fmt.Sprintf("%v=%v", formatElem(k), formatElem(v.MapIndex(k))))
+		}
+		sort.Strings(items)
+	} else {
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatElem(v.Index(i)))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func formatElem(v reflect.Value) string {
+	// copy to an addressable value as types like Duration are only a
+	// fmt.Stringer through a pointer
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	if s, ok := ptr.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -1,68 +1,135 @@
 package fortio
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
-
-	"github.com/spf13/viper"
+	"sort"
 )
 
 // CmdLineConfigLoader is config loader that makes the given config fields
 // available as a command line flags for overwriting.
 type CmdLineConfigLoader struct {
+	configStore
 }
 
 // Load will load the config field values as command line flags
 func (cmd *CmdLineConfigLoader) Load(config Config) error {
-	cfg := reflect.ValueOf(config)
-	return cmd.loadValue(cfg, "")
+	fields := make(map[string]field)
+	if err := getAllFields(config, fields); err != nil {
+		return err
+	}
+	store := cmd.values()
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if err := interpolate(store, keys); err != nil {
+		return err
+	}
+	for key, f := range fields {
+		if err := cmd.loadValue(store, reflect.ValueOf(f.addr), key); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (cmd *CmdLineConfigLoader) loadValue(dest reflect.Value, name string) error {
-	switch dest.Elem().Type().Kind() {
-	case reflect.Struct:
-		// Don't enumerate fields if interface implements StringParsable
-		if sp, ok := dest.Interface().(StringParsable); ok {
-			val := viper.GetString(name)
-			sp.ParseString(val)
-		} else {
-			for i := 0; i < dest.Elem().Type().NumField(); i++ {
-				fieldStruct := dest.Elem().Type().Field(i)
-				if err := cmd.loadValue(dest.Elem().Field(i).Addr(), lowerFirst(fieldStruct.Name)); err != nil {
-					return err
-				}
-			}
+func (cmd *CmdLineConfigLoader) loadValue(store *configValues, dest reflect.Value, name string) error {
+	// pointer fields stay nil unless a source sets a value
+	if dest.Elem().Kind() == reflect.Ptr {
+		if !store.IsSet(name) {
+			return nil
+		}
+		value := reflect.New(dest.Elem().Type().Elem())
+		if err := cmd.loadValue(store, value, name); err != nil {
+			return err
+		}
+		dest.Elem().Set(value)
+		return nil
+	}
+
+	// types parsing themselves from text, except StringList which also takes
+	// lists from config files
+	if _, ok := dest.Interface().(*StringList); !ok {
+		if parse := textParser(dest.Interface()); parse != nil {
+			return loadText(store, parse, name)
 		}
+	}
+
+	switch dest.Elem().Type().Kind() {
 	case reflect.String:
-		val := viper.GetString(name)
+		val := store.GetString(name)
 		if val != "" {
 			dest.Elem().SetString(val)
 		}
-	case reflect.Slice:
-		val := viper.GetString(name)
-
+	case reflect.Slice, reflect.Map:
+		if isCollection(dest.Interface()) {
+			raw := store.Get(name)
+			if raw == nil || raw == "" {
+				return nil
+			}
+			if err := setCollection(dest, raw); err != nil {
+				return fmt.Errorf("can't load %s - %v", name, err)
+			}
+			return nil
+		}
+		if dest.Elem().Kind() == reflect.Map {
+			return fmt.Errorf("unsupported type: %s", dest.Elem().Type())
+		}
 		sl := StringList{}
-		sl.Set(val)
+		// lists read from config files are not comma separated strings
+		if list, ok := store.Get(name).([]interface{}); ok {
+			for _, v := range list {
+				sl = append(sl, fmt.Sprint(v))
+			}
+		} else {
+			sl.Set(store.GetString(name))
+		}
 
 		dest.Elem().Set(reflect.ValueOf(sl))
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		val := viper.GetInt64(name)
+		val := store.GetInt64(name)
 		dest.Elem().SetInt(val)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		val := uint64(viper.GetInt64(name))
+		val := uint64(store.GetInt64(name))
 		dest.Elem().SetUint(val)
 	case reflect.Float32, reflect.Float64:
-		val := viper.GetFloat64(name)
+		val := store.GetFloat64(name)
 		dest.Elem().SetFloat(val)
 	case reflect.Bool:
-		val := viper.GetBool(name)
+		val := store.GetBool(name)
 		dest.Elem().SetBool(val)
 	case reflect.Interface:
 		// Skip interface hints
 	default:
+		// nested structs are enumerated by getAllFields and not loaded here
 		msg := fmt.Sprintf("unsupported type: %s", dest.Elem().Type())
 		return errors.New(msg)
 	}
 	return nil
 }
+
+// loadText parses the value of name with parse. Lists and maps from config
+// files are passed on as JSON, for types like json.Unmarshaler.
+func loadText(store *configValues, parse func(string) error, name string) error {
+	val := store.GetString(name)
+	if raw := store.Get(name); val == "" && raw != nil {
+		if _, ok := raw.(string); !ok {
+			b, err := json.Marshal(raw)
+			if err != nil {
+				return fmt.Errorf("can't load %s - %v", name, err)
+			}
+			val = string(b)
+		}
+	}
+	if val == "" {
+		return nil
+	}
+	if err := parse(val); err != nil {
+		return fmt.Errorf("can't load %s - %v", name, err)
+	}
+	return nil
+}
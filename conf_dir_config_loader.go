@@ -0,0 +1,68 @@
+package fortio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfDirConfigLoader is config loader that reads every YAML, JSON or TOML
+// file of a drop-in directory like /etc/myapp/conf.d in lexical order, later
+// files overriding values of earlier ones. Nested sections are merged key by
+// key, and lists are replaced unless their field is tagged merge=append.
+// Hidden files, sub directories and files of other formats are skipped, and
+// nothing is read if the directory doesn't exist.
+type ConfDirConfigLoader struct {
+	// Path is the drop-in directory to read
+	Path string
+
+	configStore
+}
+
+// Load will read all config files of the directory and make their values
+// available to the config fields, with lower precedence than env variables
+// and flags
+func (c *ConfDirConfigLoader) Load(config Config) error {
+	paths, err := c.files()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		configType, _ := configFileType(path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read config file %s - %v", path, err)
+		}
+		if err := mergeConfig(c.values(), data, configType, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files returns the config files of the directory in lexical order
+func (c *ConfDirConfigLoader) files() ([]string, error) {
+	entries, err := ioutil.ReadDir(c.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config directory %s - %v", c.Path, err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || entry.IsDir() {
+			continue
+		}
+		if _, err := configFileType(name); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(c.Path, name))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
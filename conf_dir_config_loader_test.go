@@ -0,0 +1,113 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ConfDirConf struct {
+	Conf
+	Primary DBConf
+	Hosts   []string `config:"merge=append;usage=Hosts"`
+	Ports   []int    `config:"merge=replace;usage=Ports"`
+	Tags    []string `config:"default=default;merge=append;usage=Tags"`
+}
+
+func TestConfDirConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	main := writeConfigFile(t, dir, "main.yaml", "name: main\nnumber: 1\nhosts: [a]\nports: [1]\ntags: [main]\n")
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	writeConfigFile(t, confDir, "20-override.json", `{
+  "name": "override",
+  "hosts": ["c"],
+  "primary": {"port": 6543}
+}`)
+	writeConfigFile(t, confDir, "10-base.yaml", "name: base\nhosts: [b]\nports: [2, 3]\nprimary:\n  host: db\n")
+	writeConfigFile(t, confDir, "30-last.toml", "ports = [4]\n")
+	writeConfigFile(t, confDir, "README", "not a config file")
+	writeConfigFile(t, confDir, ".hidden.yaml", "name: hidden\n")
+
+	c := &ConfDirConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test",
+		&FileConfigLoader{Paths: []string{main}}, &ConfDirConfigLoader{Path: confDir})
+	cm.rootCmd.SetArgs([]string{"--number", "5"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "override" || c.Number != 5 {
+		t.Errorf("Expecting later files to override earlier ones and flags to win, got %s %d", c.Name, c.Number)
+	}
+	if c.Primary.Host != "db" || c.Primary.Port != 6543 {
+		t.Errorf("Expecting nested sections to be merged, got %+v", c.Primary)
+	}
+	if !reflect.DeepEqual(c.Hosts, []string{"a", "b", "c"}) {
+		t.Errorf("Expecting hosts to be appended in lexical file order, got %v", c.Hosts)
+	}
+	if !reflect.DeepEqual(c.Ports, []int{4}) {
+		t.Errorf("Expecting ports to be replaced, got %v", c.Ports)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"main"}) {
+		t.Errorf("Expecting tags to be appended to config files only, got %v", c.Tags)
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Name" && p.Description() != "file "+filepath.Join(confDir, "20-override.json")+":2" {
+			t.Errorf("Expecting name from 20-override.json, got %s", p.Description())
+		}
+	}
+
+	// drop-ins override files given with --config, read by the FileConfigLoader
+	// the Manager adds after the given loaders
+	c = &ConfDirConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test", &ConfDirConfigLoader{Path: confDir})
+	cm.rootCmd.SetArgs([]string{"--config", main})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "override" || c.Number != 1 || !reflect.DeepEqual(c.Hosts, []string{"a", "b", "c"}) {
+		t.Errorf("Expecting drop-ins to override --config files, got %s %d %v", c.Name, c.Number, c.Hosts)
+	}
+
+	c = &ConfDirConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test", &ConfDirConfigLoader{Path: filepath.Join(dir, "missing")})
+	if err := cm.load(c, false); err != nil || c.Name != "my name" {
+		t.Errorf("Expecting missing drop-in directory to be skipped, got %s - %v", c.Name, err)
+	}
+}
+
+func TestMergeTag(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		config Config
+		err    string
+	}{
+		{&struct {
+			Conf
+			Hosts []string `config:"merge=prepend"`
+		}{}, `merge must be append or replace, not "prepend"`},
+		{&struct {
+			Conf
+			Host string `config:"merge=append"`
+		}{}, "merge only applies to lists"},
+	}
+	for _, test := range testCases {
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		err := cm.load(test.config, false)
+		if _, ok := err.(*TagError); !ok || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expecting TagError %q, got %v", test.err, err)
+		}
+	}
+}
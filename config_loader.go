@@ -1,8 +1,89 @@
 package fortio
 
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
 // ConfigLoader defines a interface that needs to be implemented by
 // a config loader to be able to plug into config manager
 type ConfigLoader interface {
 	// Load takes in a implementation of Config and populates field values
 	Load(config Config) error
 }
+
+// configValues holds the config values of a Manager, the config document
+// each config key was last read from, and the list keys whose values in
+// config documents are appended to each other instead of replaced
+type configValues struct {
+	*viper.Viper
+	origins    map[string]origin
+	appendKeys map[string]bool
+	// envKeys maps the env variables bound to config keys to their key, and
+	// flags are the flags bound to config keys
+	envKeys map[string]string
+	flags   *pflag.FlagSet
+	// dotenv maps the env variables set from .env files to their file
+	dotenv map[string]string
+}
+
+// origin is the location of a config key in a config document
+type origin struct {
+	document string
+	line     int
+}
+
+func newConfigValues() *configValues {
+	return viperValues(viper.New())
+}
+
+// viperValues returns config values held by v
+func viperValues(v *viper.Viper) *configValues {
+	return &configValues{
+		Viper:      v,
+		origins:    map[string]origin{},
+		appendKeys: map[string]bool{},
+		envKeys:    map[string]string{},
+		dotenv:     map[string]string{},
+	}
+}
+
+// flagChanged reports whether the flag of key was given on the command line
+func (s *configValues) flagChanged(key string) bool {
+	if s.flags == nil {
+		return false
+	}
+	flag := s.flags.Lookup(key)
+	return flag != nil && flag.Changed
+}
+
+// storeSetter is implemented by config loaders that read or write the config
+// values of the Manager they are plugged into
+type storeSetter interface {
+	setStore(store *configValues)
+}
+
+// loggerSetter is implemented by config loaders that log through the Logger
+// of the Manager they are plugged into
+type loggerSetter interface {
+	setLogger(logger Logger)
+}
+
+// configStore is embedded by config loaders to get the config values of the
+// Manager they are plugged into
+type configStore struct {
+	store *configValues
+}
+
+func (cs *configStore) setStore(store *configValues) {
+	cs.store = store
+}
+
+// values returns the config values of the Manager, or the global viper
+// instance when the loader is used on its own
+func (cs *configStore) values() *configValues {
+	if cs.store == nil {
+		cs.store = viperValues(viper.GetViper())
+	}
+	return cs.store
+}
@@ -3,11 +3,18 @@ package fortio
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestCmdLineConfigLoader(t *testing.T) {
+	t.Parallel()
+
 	c := &Conf{}
 
 	cm := NewConfigManager("fortio-test", "My Fortio test")
@@ -92,3 +99,195 @@ func (c *Conf) DumpJSON() (string, error) {
 func (c *Conf) DumpYAML() (string, error) {
 	return "", nil
 }
+
+type CollectionConf struct {
+	Conf
+	Ports     []int             `config:"default=80,443;usage=Ports"`
+	Ratios    []float64         `config:";usage=Ratios"`
+	Timeouts  []Duration        `config:"default=1s,2s;usage=Timeouts"`
+	Flags     []bool            `config:";usage=Flags"`
+	Tags      map[string]string `config:";usage=Tags"`
+	Limits    map[string]int    `config:"default=cpu=2;usage=Limits"`
+	Hostnames []string          `config:";usage=Hostnames"`
+}
+
+func TestCollections(t *testing.T) {
+	os.Setenv("FORTIO_TEST_RATIOS", "0.5, 1.5")
+	defer os.Unsetenv("FORTIO_TEST_RATIOS")
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "flags: [true, false]\nlimits:\n  cpu: 4\n  memory: 512\n")
+
+	c := &CollectionConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", path, "--tags", "env=prod,team=core", "--hostnames", "a", "--hostnames", "b"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if len(c.Ports) != 2 || c.Ports[0] != 80 || c.Ports[1] != 443 {
+		t.Errorf("Ports are not loaded correctly from default - %v", c.Ports)
+	}
+	if len(c.Ratios) != 2 || c.Ratios[0] != 0.5 || c.Ratios[1] != 1.5 {
+		t.Errorf("Ratios are not loaded correctly from env - %v", c.Ratios)
+	}
+	if len(c.Timeouts) != 2 || c.Timeouts[1].Duration != 2*time.Second {
+		t.Errorf("Timeouts are not loaded correctly from default - %v", c.Timeouts)
+	}
+	if len(c.Flags) != 2 || !c.Flags[0] || c.Flags[1] {
+		t.Errorf("Flags are not loaded correctly from file - %v", c.Flags)
+	}
+	if len(c.Tags) != 2 || c.Tags["env"] != "prod" || c.Tags["team"] != "core" {
+		t.Errorf("Tags are not loaded correctly from flag - %v", c.Tags)
+	}
+	if len(c.Limits) != 2 || c.Limits["cpu"] != 4 || c.Limits["memory"] != 512 {
+		t.Errorf("Limits are not loaded correctly from file - %v", c.Limits)
+	}
+	if len(c.Hostnames) != 2 || c.Hostnames[0] != "a" || c.Hostnames[1] != "b" {
+		t.Errorf("Hostnames are not loaded correctly from repeated flags - %v", c.Hostnames)
+	}
+
+	for _, p := range cm.Provenance() {
+		if p.Field == "Tags" && p.Value != "env=prod,team=core" {
+			t.Errorf("Expecting map value formatted like a flag, got %s", p.Value)
+		}
+		if p.Field == "Timeouts" && p.Value != "1s,2s" {
+			t.Errorf("Expecting list value formatted like a flag, got %s", p.Value)
+		}
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	cm.rootCmd.SetArgs([]string{"--ports", "80,http"})
+	if err := cm.load(&CollectionConf{}, true); err == nil || !strings.Contains(err.Error(), "http") {
+		t.Errorf("Expecting error for invalid list element, got %v", err)
+	}
+}
+
+type upperFlag string
+
+func (u *upperFlag) String() string { return string(*u) }
+
+func (u *upperFlag) Set(s string) error {
+	*u = upperFlag(strings.ToUpper(s))
+	return nil
+}
+
+type endpoint struct {
+	Host string
+	Port int
+}
+
+func (e *endpoint) UnmarshalJSON(b []byte) error {
+	type plain endpoint
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+type TextConf struct {
+	Conf
+	IP       net.IP    `config:"default=127.0.0.1;usage=IP"`
+	Peers    []net.IP  `config:";usage=Peers"`
+	Big      big.Int   `config:";usage=Big number"`
+	Upper    upperFlag `config:";usage=Upper cased"`
+	Endpoint endpoint  `config:";usage=Endpoint"`
+}
+
+func TestTextTypes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "endpoint:\n  host: example.com\n  port: 8080\nbig: \"123456789012345678901234567890\"\n")
+
+	c := &TextConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", path, "--peers", "10.0.0.1,10.0.0.2", "--upper", "shout"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if !c.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("TextUnmarshaler is not loaded correctly from default - %v", c.IP)
+	}
+	if len(c.Peers) != 2 || !c.Peers[1].Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Errorf("List of TextUnmarshaler is not loaded correctly - %v", c.Peers)
+	}
+	if c.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("TextUnmarshaler is not loaded correctly from file - %v", c.Big.String())
+	}
+	if c.Upper != "SHOUT" {
+		t.Errorf("flag.Value is not loaded correctly - %v", c.Upper)
+	}
+	if c.Endpoint.Host != "example.com" || c.Endpoint.Port != 8080 {
+		t.Errorf("json.Unmarshaler is not loaded correctly from file - %+v", c.Endpoint)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	cm.rootCmd.SetArgs([]string{"--ip", "not-an-ip"})
+	if err := cm.load(&TextConf{}, true); err == nil || !strings.Contains(err.Error(), "not-an-ip") {
+		t.Errorf("Expecting error for invalid IP, got %v", err)
+	}
+}
+
+type PointerConf struct {
+	Conf
+	Port    *int      `config:";usage=Port"`
+	Timeout *Duration `config:"default=1s;usage=Timeout"`
+	Debug   *bool     `config:";usage=Debug"`
+	Label   *string   `config:";usage=Label"`
+	Ratio   *float64  `config:"required;max=1;usage=Ratio"`
+	IP      *net.IP   `config:";usage=IP"`
+}
+
+func TestPointerFields(t *testing.T) {
+	t.Parallel()
+
+	c := &PointerConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--port", "0", "--ratio", "0.5", "--ip", "10.0.0.1"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if c.Port == nil || *c.Port != 0 {
+		t.Errorf("Expecting Port set to 0 to be allocated - %v", c.Port)
+	}
+	if c.Timeout == nil || c.Timeout.Duration != time.Second {
+		t.Errorf("Expecting Timeout to be allocated with default - %v", c.Timeout)
+	}
+	if c.Debug != nil || c.Label != nil {
+		t.Errorf("Expecting unset pointer fields to stay nil - %v %v", c.Debug, c.Label)
+	}
+	if c.Ratio == nil || *c.Ratio != 0.5 || c.IP == nil || c.IP.String() != "10.0.0.1" {
+		t.Errorf("Pointer fields are not loaded correctly - %v %v", c.Ratio, c.IP)
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Port" && (p.Source != SourceFlag || p.Value != "0") {
+			t.Errorf("Expecting Port from flag, got %+v", p)
+		}
+		if p.Field == "Debug" && p.Source != SourceUnset {
+			t.Errorf("Expecting Debug to be unset, got %+v", p)
+		}
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--port", "1"})
+	err := cm.load(&PointerConf{}, true)
+	if missing, ok := err.(*MissingFieldsError); !ok || len(missing.Fields) != 1 || missing.Fields[0].Name != "Ratio" {
+		t.Errorf("Expecting required pointer field Ratio to be missing, got %v", err)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--ratio", "2"})
+	if _, ok := cm.load(&PointerConf{}, true).(*InvalidFieldsError); !ok {
+		t.Errorf("Expecting constraints to apply to pointer fields")
+	}
+}
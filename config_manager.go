@@ -1,6 +1,7 @@
 package fortio
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 
@@ -8,27 +9,34 @@ import (
 
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"log"
 
-	"bytes"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"github.com/spf13/viper"
 )
 
 const (
 	tagName = "config"
 
+	// list merge strategies of the merge= tag
+	mergeReplace = "replace"
+	mergeAppend  = "append"
+
 	environmentVariable namespace = "env"
 	configURL           namespace = "url"
 )
 
 var camelCaseRegex = regexp.MustCompile("(^[^A-Z]*|[A-Z]*)([A-Z][^A-Z]+|$)")
 
+var envPrefixRegex = regexp.MustCompile("[^A-Z0-9]+")
+
 // namespace identifies the which namespace the config belongs to like
 // environment variable, cassandra registry or kafka registry, so to
 // parse accordingly
@@ -38,17 +46,51 @@ type namespace string
 // config loaders to NewConfigManager API
 type Manager struct {
 	appName       string
+	envPrefix     string
 	rootCmd       *cobra.Command
 	logger        Logger
 	configLoaders []ConfigLoader
+	urlLoaders    []ConfigLoader
+	// httpLoaders are the loaders of url= fields by config key, kept across
+	// loads so their ETag cache survives reloads
+	httpLoaders map[string]*HTTPConfigLoader
+	// store holds the config values of this Manager, separate from any other
+	// Manager in the process
+	store *configValues
+	// fields are the config fields found by the last createCommandLineFlags
+	fields map[string]field
+	// commandErr is set by commands that replace loading the config, like
+	// version, and returned by Load
+	commandErr error
+	// profile is the active profile of the last load
+	profile string
+	// envFileSizeLimit is the largest file read through a _FILE env variable
+	envFileSizeLimit int64
+	// sampleFormat and docsFormat are the formats of the sample-config and
+	// docs commands
+	sampleFormat string
+	docsFormat   string
+
+	// current holds the last successfully loaded config for Watch
+	current  atomic.Value
+	onChange []func(old, new Config)
+	mu       sync.Mutex
+	watching chan struct{}
 }
 
 // NewConfigManagerWithRootCmd returns a configManager using the provided rootCmd
 func NewConfigManagerWithRootCmd(rootCmd *cobra.Command, configLoaders ...ConfigLoader) *Manager {
-	return &Manager{
+	store := newConfigValues()
+	addConfigFlag(rootCmd)
+
+	cm := &Manager{
+		envPrefix:     envPrefix(rootCmd.Name()),
 		rootCmd:       rootCmd,
-		configLoaders: configLoaders,
+		configLoaders: withStore(store, withFileConfigLoader(configLoaders)),
+		store:         store,
 	}
+	cm.SetLogger(NewStdLogger(3, log.Ldate|log.Ltime))
+	return cm
 }
 
 // NewConfigManager returns new instance of ConfigManager
@@ -70,36 +112,138 @@ func NewConfigManager(appName, description string, configLoaders ...ConfigLoader
 	rootCmd.SetHelpCommand(helpCmd)
 	rootCmd.AddCommand(helpCmd)
 
+	store := newConfigValues()
+	addConfigFlag(rootCmd)
+
+	cm := &Manager{
+		appName:       appName,
+		envPrefix:     envPrefix(appName),
+		rootCmd:       rootCmd,
+		configLoaders: withStore(store, append(withFileConfigLoader(configLoaders), &CmdLineConfigLoader{})),
+		store:         store,
+	}
+	cm.SetLogger(NewStdLogger(3, log.Ldate|log.Ltime))
+
 	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Version of Config Manager",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("1.0")
-			os.Exit(0)
+			fmt.Fprintln(cmd.OutOrStdout(), "1.0")
+			cm.commandErr = ErrVersionRequested
 		},
 	}
 
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(cm.explainCommand())
+	rootCmd.AddCommand(cm.schemaCommand())
+	rootCmd.AddCommand(cm.sampleConfigCommand())
+	rootCmd.AddCommand(cm.docsCommand())
 
-	return &Manager{
-		appName:       appName,
-		logger:        NewStdLogger(3, log.Ldate|log.Ltime),
-		rootCmd:       rootCmd,
-		configLoaders: append(configLoaders, &CmdLineConfigLoader{}),
+	return cm
+}
+
+// addConfigFlag registers the --config flag used by FileConfigLoader to find
+// config files, and the --profile flag selecting profile files and defaults
+func addConfigFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSlice(configFlag, nil, "config files to load (yaml, json or toml)")
+	cmd.PersistentFlags().String(profileFlag, "", "profile like dev or prod, selecting profile config files and defaults")
+}
+
+// withStore hands the config store of a Manager to the given loaders that
+// read or write config values
+func withStore(store *configValues, configLoaders []ConfigLoader) []ConfigLoader {
+	for _, loader := range configLoaders {
+		if s, ok := loader.(storeSetter); ok {
+			s.setStore(store)
+		}
+	}
+	return configLoaders
+}
+
+// withFileConfigLoader appends a FileConfigLoader to given loaders unless one
+// is already present, so files passed with --config are always loaded. Drop-in
+// directories of ConfDirConfigLoaders are moved after the last
+// FileConfigLoader, so their files override the main config files.
+func withFileConfigLoader(configLoaders []ConfigLoader) []ConfigLoader {
+	hasFileLoader := false
+	for _, loader := range configLoaders {
+		if _, ok := loader.(*FileConfigLoader); ok {
+			hasFileLoader = true
+		}
+	}
+	if !hasFileLoader {
+		configLoaders = append(configLoaders, &FileConfigLoader{})
+	}
+
+	last := 0
+	for i, loader := range configLoaders {
+		if _, ok := loader.(*FileConfigLoader); ok {
+			last = i
+		}
+	}
+	var ordered, confDirs []ConfigLoader
+	for i, loader := range configLoaders {
+		if _, ok := loader.(*ConfDirConfigLoader); ok && i < last {
+			confDirs = append(confDirs, loader)
+			continue
+		}
+		ordered = append(ordered, loader)
+		if i == last {
+			ordered = append(ordered, confDirs...)
+		}
 	}
+	return ordered
 }
 
 // SetLogger will set given logger and uses it for logging
 func (cm *Manager) SetLogger(logger Logger) {
 	cm.logger = logger
+	for _, loader := range cm.configLoaders {
+		if l, ok := loader.(loggerSetter); ok {
+			l.setLogger(logger)
+		}
+	}
+}
+
+// SetEnvPrefix sets the prefix of the env variable names of config fields, so
+// with prefix MYAPP field Port is read from MYAPP_PORT. It defaults to the
+// upper cased app name, an empty prefix reads fields from their bare names.
+// Fields with an env= tag are read from exactly that name.
+func (cm *Manager) SetEnvPrefix(prefix string) {
+	cm.envPrefix = prefix
+}
+
+// envPrefix turns an app name like my-app into an env variable prefix MY_APP
+func envPrefix(appName string) string {
+	return strings.Trim(envPrefixRegex.ReplaceAllString(strings.ToUpper(appName), "_"), "_")
 }
 
 // Load will create command line flags for given config and loads values into
-// it from environment variables
+// it from environment variables. When help, version or explain are requested
+// Load prints them and returns ErrHelpRequested, ErrVersionRequested or
+// ErrExplainRequested, and likewise ErrSchemaRequested for schema,
+// ErrSampleConfigRequested for sample-config and ErrDocsRequested for docs.
 func (cm *Manager) Load(config Config) error {
 	return cm.load(config, true)
 }
 
+// LoadOrExit loads config like Load but exits the process when loading
+// fails, with status 0 if help, version, explain, schema, sample-config or
+// docs were requested
+func (cm *Manager) LoadOrExit(config Config) {
+	err := cm.Load(config)
+	switch err {
+	case nil:
+		return
+	case ErrHelpRequested, ErrVersionRequested, ErrExplainRequested, ErrSchemaRequested, ErrSampleConfigRequested,
+		ErrDocsRequested:
+		os.Exit(0)
+	default:
+		cm.logger.Errorf("Unable to load config - %v", err)
+		os.Exit(1)
+	}
+}
+
 func (cm *Manager) load(config Config, execute bool) error {
 	err := cm.createCommandLineFlags(cm.rootCmd, config)
 	if err != nil {
@@ -109,155 +253,366 @@ func (cm *Manager) load(config Config, execute bool) error {
 
 	cm.rootCmd.Run = func(cmd *cobra.Command, args []string) {}
 
+	cm.commandErr = nil
 	if execute {
 		if err := cm.rootCmd.Execute(); err != nil {
-			cm.logger.Debugf("Command line args: %+v", os.Args)
+			cm.logger.Debugf("Command line args: %+v", cm.redactArgs(os.Args))
 			cm.logger.Errorf("Error executing rootCmd - %v", err)
 			return err
 		}
 	}
 
-	for _, loader := range cm.configLoaders {
-		err := loader.Load(config)
+	helpIsSet, _ := cm.rootCmd.Flags().GetBool("help")
+	if helpIsSet {
+		return ErrHelpRequested
+	}
+	if cm.commandErr == ErrSchemaRequested {
+		schema, err := cm.Schema(config)
 		if err != nil {
 			return err
 		}
+		fmt.Fprintln(cm.rootCmd.OutOrStdout(), string(schema))
+		return ErrSchemaRequested
+	}
+	if cm.commandErr == ErrSampleConfigRequested {
+		if err := cm.SampleConfig(cm.rootCmd.OutOrStdout(), config, cm.sampleFormat); err != nil {
+			return err
+		}
+		return ErrSampleConfigRequested
+	}
+	if cm.commandErr == ErrDocsRequested {
+		if err := cm.Docs(cm.rootCmd.OutOrStdout(), config, cm.docsFormat); err != nil {
+			return err
+		}
+		return ErrDocsRequested
+	}
+	if cm.commandErr != nil && cm.commandErr != ErrExplainRequested {
+		return cm.commandErr
 	}
 
-	helpIsSet, _ := cm.rootCmd.Flags().GetBool("help")
-	if helpIsSet {
-		os.Exit(0)
+	cm.applyProfile()
+	if err := cm.loadEnvFiles(); err != nil {
+		return err
+	}
+	if err := cm.runLoaders(config); err != nil {
+		return err
+	}
+
+	if cm.commandErr == ErrExplainRequested {
+		cm.Explain(cm.rootCmd.OutOrStdout())
+		return ErrExplainRequested
 	}
 
+	if err := cm.checkRequired(); err != nil {
+		return err
+	}
+	if err := cm.checkConstraints(); err != nil {
+		return err
+	}
+	cm.current.Store(config)
+	return nil
+}
+
+// applyProfile replaces the defaults of fields with the defaults of the
+// active profile, set by --profile or its env variable
+func (cm *Manager) applyProfile() {
+	cm.profile = cm.store.GetString(profileFlag)
+	for key, f := range cm.fields {
+		if value, ok := f.profileDefaults[cm.profile]; ok {
+			cm.store.SetDefault(key, value)
+		}
+	}
+}
+
+// runLoaders loads values into config from all config loaders
+func (cm *Manager) runLoaders(config Config) error {
+	for _, loader := range append(cm.urlLoaders, cm.configLoaders...) {
+		err := loader.Load(config)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // createCommandLineFlags will create command line flags for given config via Cobra and Viper
 // to support command line overriding of config values
 func (cm *Manager) createCommandLineFlags(cmd *cobra.Command, config interface{}) error {
-	fields := make(map[string]field)
-	getAllFields(config, fields)
-	for name, field := range fields {
-		lFirst := lowerFirst(name)
+	fields, err := cm.configFields(config)
+	if err != nil {
+		return err
+	}
+	cm.fields = fields
+	cm.urlLoaders = nil
+	cm.store.flags = cmd.PersistentFlags()
+	cm.store.BindPFlag(configFlag, cmd.PersistentFlags().Lookup(configFlag))
+	cm.store.BindPFlag(profileFlag, cmd.PersistentFlags().Lookup(profileFlag))
+	cm.store.BindEnv(profileFlag, joinKey(cm.envPrefix, "PROFILE", "_"))
+	for key, field := range fields {
+		for _, value := range field.profileDefaults {
+			if hasReference(value) {
+				continue
+			}
+			if err := checkDefault(field.addr, value); err != nil {
+				return &DefaultParseError{Field: field.name, Type: fieldType(field.addr).String(), Value: value, Err: err}
+			}
+		}
+
+		if field.merge == mergeAppend {
+			cm.store.appendKeys[strings.ToLower(key)] = true
+		}
 
-		switch ptr := field.addr.(type) {
+		if hasReference(field.defaultValue) {
+			// defaults with references are parsed once they are resolved
+			cm.store.SetDefault(key, field.defaultValue)
+			field.defaultValue = ""
+		}
+
+		// flags are defined once, loading again only binds them to the store
+		flags := pflag.NewFlagSet(key, pflag.ContinueOnError)
+		addr := field.addr
+		if v := reflect.ValueOf(addr).Elem(); v.Kind() == reflect.Ptr {
+			// pointer fields get the flag of the type they point to, the
+			// pointer is only allocated by CmdLineConfigLoader when set
+			addr = reflect.New(v.Type().Elem()).Interface()
+		}
+		switch ptr := addr.(type) {
 		case *string:
 			if field.defaultValue != "" {
-				viper.SetDefault(lFirst, field.defaultValue)
+				cm.store.SetDefault(key, field.defaultValue)
 			}
-			cmd.PersistentFlags().String(lFirst, *ptr, field.usage)
+			flags.String(key, *ptr, field.usage)
 		case *int:
-			val, err := strconv.Atoi(field.defaultValue)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a int", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.Atoi(field.defaultValue)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "int", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Int(lFirst, *ptr, field.usage)
+			flags.Int(key, *ptr, field.usage)
 		case *int8:
-			val, err := strconv.ParseInt(field.defaultValue, 10, 8)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a int8 val=%v defaultValue=%v", field.name, val, field.defaultValue)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseInt(field.defaultValue, 10, 8)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "int8", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Int8(lFirst, *ptr, field.usage)
+			flags.Int8(key, *ptr, field.usage)
 		case *int32:
-			val, err := strconv.ParseInt(field.defaultValue, 10, 32)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a int32 val=%v defaultValue=%v", field.name, val, field.defaultValue)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseInt(field.defaultValue, 10, 32)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "int32", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Int32(lFirst, *ptr, field.usage)
+			flags.Int32(key, *ptr, field.usage)
 		case *int64:
-			val, err := strconv.ParseInt(field.defaultValue, 10, 64)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a int64 val=%v defaultValue=%v", field.name, val, field.defaultValue)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseInt(field.defaultValue, 10, 64)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "int64", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Int64(lFirst, *ptr, field.usage)
+			flags.Int64(key, *ptr, field.usage)
 		case *uint:
-			val, err := strconv.Atoi(field.defaultValue)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a uint", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.Atoi(field.defaultValue)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "uint", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Uint(lFirst, *ptr, field.usage)
+			flags.Uint(key, *ptr, field.usage)
 		case *uint8:
-			val, err := strconv.ParseUint(field.defaultValue, 10, 8)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a uint8", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseUint(field.defaultValue, 10, 8)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "uint8", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Uint8(lFirst, *ptr, field.usage)
+			flags.Uint8(key, *ptr, field.usage)
 		case *uint16:
-			val, err := strconv.ParseUint(field.defaultValue, 10, 16)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a uint16", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseUint(field.defaultValue, 10, 16)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "uint16", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Uint16(lFirst, *ptr, field.usage)
+			flags.Uint16(key, *ptr, field.usage)
 		case *uint32:
-			val, err := strconv.ParseUint(field.defaultValue, 10, 32)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a uint32", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseUint(field.defaultValue, 10, 32)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "uint32", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Uint32(lFirst, *ptr, field.usage)
+			flags.Uint32(key, *ptr, field.usage)
 		case *uint64:
-			val, err := strconv.ParseUint(field.defaultValue, 10, 64)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a uint64", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseUint(field.defaultValue, 10, 64)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "uint64", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Uint64(lFirst, *ptr, field.usage)
+			flags.Uint64(key, *ptr, field.usage)
 		case *float32:
-			val, err := strconv.ParseFloat(field.defaultValue, 32)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a float32", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseFloat(field.defaultValue, 32)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "float32", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Float32(lFirst, *ptr, field.usage)
+			flags.Float32(key, *ptr, field.usage)
 		case *float64:
-			val, err := strconv.ParseFloat(field.defaultValue, 64)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a float64", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseFloat(field.defaultValue, 64)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "float64", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Float64(lFirst, *ptr, field.usage)
+			flags.Float64(key, *ptr, field.usage)
 		case *bool:
-			val, err := strconv.ParseBool(field.defaultValue)
-			if err != nil {
-				cm.logger.Fatalf("default specified for %s is not a bool", field.name)
+			if field.defaultValue != "" {
+				val, err := strconv.ParseBool(field.defaultValue)
+				if err != nil {
+					return &DefaultParseError{Field: field.name, Type: "bool", Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, val)
+			}
+			flags.Bool(key, *ptr, field.usage)
+		case *Secret:
+			// the flag holds the plain value, Secret only shows it redacted
+			if field.defaultValue != "" {
+				if err := checkDefault(ptr, field.defaultValue); err != nil {
+					return &DefaultParseError{Field: field.name, Type: "fortio.Secret", Value: redacted, Err: err}
+				}
+				cm.store.SetDefault(key, field.defaultValue)
 			}
-			viper.SetDefault(lFirst, val)
-			cmd.PersistentFlags().Bool(lFirst, *ptr, field.usage)
+			flags.String(key, "", field.usage)
 		case pflag.Value:
-			// Any type implementing pflag.Value will be automatically supported
-			viper.SetDefault(lFirst, field.defaultValue)
-			cmd.PersistentFlags().Var(ptr, lFirst, field.usage)
+			// Any type implementing pflag.Value will be automatically supported.
+			// The flag only holds the value given on the command line, so it
+			// stays unbound from the field of the config passed to Load.
+			if field.defaultValue != "" {
+				if err := checkDefault(ptr, field.defaultValue); err != nil {
+					return &DefaultParseError{Field: field.name, Type: ptr.Type(), Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, field.defaultValue)
+			}
+			flags.Var(&textFlag{typ: ptr.Type()}, key, field.usage)
 		default:
-			cm.logger.Warnf("unknown field %s type %v", field.name, reflect.TypeOf(field))
+			if textParser(ptr) != nil {
+				// types like net.IP or big.Int are parsed by CmdLineConfigLoader
+				// from the flag value
+				typ := reflect.TypeOf(ptr).Elem()
+				if field.defaultValue != "" {
+					if err := textParser(reflect.New(typ).Interface())(field.defaultValue); err != nil {
+						return &DefaultParseError{Field: field.name, Type: typ.String(), Value: field.defaultValue, Err: err}
+					}
+					cm.store.SetDefault(key, field.defaultValue)
+				}
+				flags.Var(&textFlag{typ: typ.String()}, key, field.usage)
+				break
+			}
+			if !isCollection(ptr) {
+				cm.logger.Warnf("unknown field %s type %v", field.name, reflect.TypeOf(field))
+				break
+			}
+			// slices and maps are parsed by CmdLineConfigLoader from the
+			// comma separated flag value, or the list or map of a config file
+			typ := reflect.TypeOf(ptr).Elem()
+			if field.defaultValue != "" {
+				if err := setCollection(reflect.New(typ), field.defaultValue); err != nil {
+					return &DefaultParseError{Field: field.name, Type: typ.String(), Value: field.defaultValue, Err: err}
+				}
+				cm.store.SetDefault(key, field.defaultValue)
+			}
+			flags.Var(&listFlag{typ: typ.String()}, key, field.usage)
 		}
 
-		underscoreField := camelCaseToUnderscore(name)
 		switch field.namespace {
 		case environmentVariable:
-			if field.env != "" {
-				viper.BindEnv(lFirst, strings.ToUpper(field.env))
-			} else {
-				viper.BindEnv(lFirst, underscoreField)
-			}
+			cm.store.BindEnv(key, field.envName)
+			cm.store.envKeys[field.envName] = key
 		case configURL:
 			if field.url != "" {
-				viper.BindEnv(lFirst, field.url)
+				loader, ok := cm.httpLoaders[key]
+				if !ok || loader.URL != field.url {
+					loader = &HTTPConfigLoader{URL: field.url, Field: key}
+					if cm.httpLoaders == nil {
+						cm.httpLoaders = map[string]*HTTPConfigLoader{}
+					}
+					cm.httpLoaders[key] = loader
+				}
+				loader.setStore(cm.store)
+				loader.setLogger(cm.logger)
+				cm.urlLoaders = append(cm.urlLoaders, loader)
 			} else {
 				return fmt.Errorf("url tag can't be empty")
 			}
 		}
-		viper.BindPFlag(lFirst, cmd.PersistentFlags().Lookup(lFirst))
+		if flag := flags.Lookup(key); flag != nil && cmd.PersistentFlags().Lookup(key) == nil {
+			cmd.PersistentFlags().AddFlag(flag)
+		}
+		cm.store.BindPFlag(key, cmd.PersistentFlags().Lookup(key))
 	}
 	return nil
 }
 
+// configFields returns the config fields of config keyed by config key, with
+// the env variable names of this Manager
+func (cm *Manager) configFields(config interface{}) (map[string]field, error) {
+	fields := make(map[string]field)
+	if err := getAllFields(config, fields); err != nil {
+		return nil, err
+	}
+	for key, field := range fields {
+		if field.namespace == environmentVariable && field.env == "" {
+			field.envName = joinKey(cm.envPrefix, field.envName, "_")
+			fields[key] = field
+		}
+	}
+	for _, field := range orderedFields(fields) {
+		for _, flag := range []string{configFlag, profileFlag} {
+			if strings.EqualFold(field.key, flag) {
+				return nil, fmt.Errorf("config key %s of %s collides with the built-in --%s flag", field.key, field.name, flag)
+			}
+		}
+	}
+	// fields like DBHost and DB.Host have different keys but the same env name
+	envNames := map[string]string{}
+	for _, field := range orderedFields(fields) {
+		if field.envName == "" {
+			continue
+		}
+		if existing, ok := envNames[field.envName]; ok {
+			return nil, fmt.Errorf("env variable %s of %s collides with %s", field.envName, field.name, existing)
+		}
+		envNames[field.envName] = field.name
+	}
+	return fields, nil
+}
+
+// orderedFields returns fields in the order of the config struct
+func orderedFields(fields map[string]field) []field {
+	ordered := make([]field, 0, len(fields))
+	for _, f := range fields {
+		ordered = append(ordered, f)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].order < ordered[j].order })
+	return ordered
+}
+
 // CreateCommandLineFlags will create command line flags for given config via Cobra and Viper
 // to support command line overriding of config values
 func (cm *Manager) CreateCommandLineFlags(config interface{}) error {
@@ -267,12 +622,25 @@ func (cm *Manager) CreateCommandLineFlags(config interface{}) error {
 type field struct {
 	addr         interface{}
 	name         string
+	key          string
+	envName      string
 	defaultValue string
 	namespace    namespace
 	usage        string
+	description  string
 	env          string
 	url          string
+	prefix       string
+	merge        string
 	required     bool
+	secret       bool
+	// noFile disables reading the value from the file named by <ENV>_FILE
+	noFile      bool
+	validations []validation
+	// profileDefaults are the defaults of profiles from default.<profile>=
+	profileDefaults map[string]string
+	// order is the position of the field in the config struct
+	order int
 }
 
 // Turn the first character in a camel case string to lowercase
@@ -309,49 +677,156 @@ func lowerFirst(s string) string {
 	return string(result)
 }
 
-func getAllFields(obj interface{}, m map[string]field) {
+// getAllFields collects the config fields of obj into m keyed by their config
+// key. Fields of nested structs are prefixed with the key of the struct field,
+// like db.host, while fields of embedded structs are treated as fields of obj.
+func getAllFields(obj interface{}, m map[string]field) error {
 	xv := reflect.ValueOf(obj).Elem() // Dereference into addressable value
+	return getFields(xv, "", "", "", m)
+}
+
+// getFields collects the config fields of struct xv, prefixing field names with
+// path, config keys with key and env variable names with env
+func getFields(xv reflect.Value, path, key, env string, m map[string]field) error {
 	xt := xv.Type()
 
 	for i := 0; i < xt.NumField(); i++ {
 		f := xt.Field(i)
-		if f.Anonymous {
-			getAllFields(xv.Field(i).Addr().Interface(), m)
-		} else {
-			addr := xv.Field(i).Addr().Interface()
+		if f.PkgPath != "" && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			// unexported fields can't be set
+			continue
+		}
+		fld, err := getField(f)
+		if err != nil {
+			return &TagError{Field: joinKey(path, f.Name, "."), Tag: f.Tag.Get(tagName), Err: err}
+		}
+
+		if isNested(xv.Field(i)) {
+			nestedPath, nestedKey, nestedEnv := path, key, env
+			if !f.Anonymous {
+				nestedPath = joinKey(path, f.Name, ".")
+				nestedKey = joinKey(key, lowerFirst(f.Name), ".")
+				nestedEnv = joinKey(env, camelCaseToUnderscore(f.Name), "_")
+			}
+			if fld.prefix != "" {
+				nestedKey = joinKey(key, fld.prefix, ".")
+				nestedEnv = joinKey(env, camelCaseToUnderscore(strings.Replace(fld.prefix, ".", "_", -1)), "_")
+			}
+			if err := getFields(xv.Field(i), nestedPath, nestedKey, nestedEnv, m); err != nil {
+				return err
+			}
+			continue
+		}
 
-			fld := getField(xt.Field(i))
-			fld.name = f.Name
-			fld.addr = addr
-			// add defaults to help, cobra/viper doesn't let us add this
-			// and no clear example on how to use SetHelpTemplate
-			fld.usage = fmt.Sprintf("%s [default: %v]", fld.usage, fld.defaultValue)
-			m[f.Name] = fld
+		addr := xv.Field(i).Addr().Interface()
+		fld.name = joinKey(path, f.Name, ".")
+		fld.key = joinKey(key, lowerFirst(f.Name), ".")
+		fld.addr = addr
+		if fld.merge != "" && fieldType(addr).Kind() != reflect.Slice {
+			return &TagError{Field: fld.name, Tag: f.Tag.Get(tagName), Err: errors.New("merge only applies to lists")}
 		}
+		if fld.namespace == environmentVariable {
+			if fld.env != "" {
+				fld.envName = strings.ToUpper(fld.env)
+			} else {
+				fld.envName = joinKey(env, camelCaseToUnderscore(f.Name), "_")
+			}
+		}
+		switch addr.(type) {
+		case *Secret, **Secret:
+			fld.secret = true
+		}
+		// add defaults to help, cobra/viper doesn't let us add this
+		// and no clear example on how to use SetHelpTemplate
+		defaultValue := fld.defaultValue
+		if fld.secret && defaultValue != "" {
+			defaultValue = redacted
+		}
+		fld.description = fld.usage
+		fld.usage = fmt.Sprintf("%s [default: %v%s]", fld.usage, defaultValue, fld.profileUsage())
+
+		if existing, ok := m[fld.key]; ok {
+			return fmt.Errorf("config key %s of %s collides with %s", fld.key, fld.name, existing.name)
+		}
+		fld.order = len(m)
+		m[fld.key] = fld
+	}
+	return nil
+}
+
+// isNested reports whether the struct field v holds a nested config struct,
+// rather than a value that parses itself from text like Duration. Unexported
+// embedded structs are always nested, only their exported fields can be set.
+func isNested(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	if !v.Addr().CanInterface() {
+		return true
 	}
+	return textParser(v.Addr().Interface()) == nil
 }
 
-func getField(fld reflect.StructField) field {
+// joinKey joins a nested key to its prefix with sep
+func joinKey(prefix, key, sep string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + sep + key
+}
+
+func getField(fld reflect.StructField) (field, error) {
 	f := field{
-		required: false,
-	}
-	tags := strings.Split(fld.Tag.Get(tagName), ";")
-	for _, tag := range tags {
-		t := strings.Split(tag, "=")
-		if t[0] == "default" {
-			f.defaultValue = t[1]
-		} else if t[0] == "usage" {
-			f.usage = t[1]
-		} else if t[0] == "env" {
-			f.env = t[1]
-		} else if t[0] == "required" {
+		required:  false,
+		namespace: environmentVariable,
+	}
+	entries, err := parseTag(fld.Tag.Get(tagName))
+	if err != nil {
+		return f, err
+	}
+	for _, t := range entries {
+		if err := checkTagEntry(t); err != nil {
+			return f, err
+		}
+		if t.key == "default" {
+			f.defaultValue = t.value
+		} else if strings.HasPrefix(t.key, profileDefaultPrefix) {
+			if t.key == profileDefaultPrefix {
+				return f, fmt.Errorf("missing profile name in %s", t.key)
+			}
+			if f.profileDefaults == nil {
+				f.profileDefaults = map[string]string{}
+			}
+			f.profileDefaults[strings.TrimPrefix(t.key, profileDefaultPrefix)] = t.value
+		} else if t.key == "usage" {
+			f.usage = t.value
+		} else if t.key == "env" {
+			f.env = t.value
+		} else if t.key == "required" {
 			f.required = true
-		} else if t[0] == "url" {
-			f.url = t[1]
+		} else if t.key == "secret" {
+			f.secret = true
+		} else if t.key == "nofile" {
+			f.noFile = true
+		} else if t.key == "prefix" {
+			f.prefix = t.value
+		} else if t.key == "merge" {
+			if t.value != mergeAppend && t.value != mergeReplace {
+				return f, fmt.Errorf("merge must be %s or %s, not %q", mergeAppend, mergeReplace, t.value)
+			}
+			f.merge = t.value
+		} else if t.key == "url" {
+			f.url = t.value
+			f.namespace = configURL
+		} else if validationTags[t.key] {
+			v, err := newValidation(t.key, t.value, fld.Type)
+			if err != nil {
+				return f, err
+			}
+			f.validations = append(f.validations, v)
 		}
-
 	}
-	return f
+	return f, nil
 }
 
 func camelCaseToUnderscore(s string) string {
@@ -367,20 +842,49 @@ func camelCaseToUnderscore(s string) string {
 	return strings.ToUpper(strings.Join(out, "_"))
 }
 
-// StdinConfigLoader provides autowiring of config values piped in from stdin
-type StdinConfigLoader struct{}
-
-// Load trigger recursive load of config values from yaml piped to stdin
-func (s *StdinConfigLoader) Load(config interface{}) error {
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(os.Stdin)
+// defaultFor returns the default of the field in given profile
+func (f field) defaultFor(profile string) string {
+	if value, ok := f.profileDefaults[profile]; ok {
+		return value
+	}
+	return f.defaultValue
+}
 
-		viper.SetConfigType("yaml")
-		if err := viper.ReadConfig(buf); err != nil {
-			return err
+// profileUsage lists the profile defaults for help, like ", prod: 10"
+func (f field) profileUsage() string {
+	var profiles []string
+	for profile := range f.profileDefaults {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	var usage string
+	for _, profile := range profiles {
+		value := f.profileDefaults[profile]
+		if f.secret && value != "" {
+			value = redacted
 		}
+		usage += fmt.Sprintf(", %s: %s", profile, value)
 	}
-	return nil
+	return usage
+}
+
+// fieldType returns the type of the value of the field at addr, the type it
+// points to for pointer fields
+func fieldType(addr interface{}) reflect.Type {
+	t := reflect.TypeOf(addr).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// checkDefault returns an error if value is not a valid value of the field
+// at addr
+func checkDefault(addr interface{}, value string) error {
+	v := reflect.New(fieldType(addr))
+	if isCollection(v.Interface()) {
+		return setCollection(v, value)
+	}
+	_, err := parseElem(v.Elem().Type(), value)
+	return err
 }
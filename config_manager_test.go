@@ -1,6 +1,13 @@
 package fortio
 
-import "testing"
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestCamelCaseToUnderscore(t *testing.T) {
 	var testCases = [][]string{
@@ -36,6 +43,438 @@ func TestCamelCaseToLowerFirst(t *testing.T) {
 	}
 }
 
+type RequiredConf struct {
+	Conf
+	Host     string `config:"required;usage=Host to connect to"`
+	Port     int    `config:"required;usage=Port to connect to"`
+	User     string `config:"env=FORTIO_TEST_REQUIRED_USER;required;usage=User name"`
+	Protocol string `config:"required;default=tcp;usage=Protocol to use"`
+	Optional string `config:";usage=Not required"`
+}
+
 func TestRequired(t *testing.T) {
+	os.Setenv("FORTIO_TEST_REQUIRED_USER", "admin")
+	defer os.Unsetenv("FORTIO_TEST_REQUIRED_USER")
+
+	c := &RequiredConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	err := cm.load(c, false)
+	if err == nil {
+		t.Fatalf("Expecting error for missing required fields")
+	}
+
+	missing, ok := err.(*MissingFieldsError)
+	if !ok {
+		t.Fatalf("Expecting MissingFieldsError, got %T - %v", err, err)
+	}
+	if len(missing.Fields) != 2 || missing.Fields[0].Name != "Host" || missing.Fields[1].Name != "Port" {
+		t.Errorf("Expecting Host and Port to be missing, got %+v", missing.Fields)
+	}
+	for _, name := range []string{"--host", "HOST", "--port", "PORT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expecting error to mention %s - %s", name, err.Error())
+		}
+	}
 
+	c = &RequiredConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--host", "localhost", "--port", "0"})
+	if err := cm.load(c, true); err != nil {
+		t.Errorf("Config loading not supposed to fail when required fields are set - %s", err.Error())
+	}
+	if c.User != "admin" || c.Protocol != "tcp" {
+		t.Errorf("Required fields are not loaded correctly - %s %s", c.User, c.Protocol)
+	}
+}
+
+func TestManagersAreIndependent(t *testing.T) {
+	t.Parallel()
+
+	first, second := &Conf{}, &Conf{}
+	firstCm := NewConfigManager("fortio-test", "My Fortio test")
+	secondCm := NewConfigManager("fortio-test", "My Fortio test")
+	firstCm.rootCmd.SetArgs([]string{"--name", "first"})
+	secondCm.rootCmd.SetArgs([]string{"--number", "2"})
+
+	if err := firstCm.load(first, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if err := secondCm.load(second, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if first.Name != "first" || first.Number != -10 {
+		t.Errorf("First config is not loaded correctly - %s %d", first.Name, first.Number)
+	}
+	if second.Name != "my name" || second.Number != 2 {
+		t.Errorf("Second config is affected by first manager - %s %d", second.Name, second.Number)
+	}
+}
+
+type BadDefaultConf struct {
+	Conf
+	Port int `config:"default=eighty;usage=Port"`
+}
+
+type BadDurationDefaultConf struct {
+	Conf
+	Wait Duration `config:"default=soon;usage=Wait"`
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		args   []string
+		err    error
+		output string
+	}{
+		{[]string{"--help"}, ErrHelpRequested, "Usage:"},
+		{[]string{"version"}, ErrVersionRequested, "1.0"},
+		{[]string{"explain", "--name", "explained"}, ErrExplainRequested, "explained"},
+	}
+
+	for _, test := range testCases {
+		buf := &bytes.Buffer{}
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.rootCmd.SetArgs(test.args)
+		cm.rootCmd.SetOutput(buf)
+		if err := cm.load(&Conf{}, true); err != test.err {
+			t.Errorf("Expecting %v for %v, got %v", test.err, test.args, err)
+		}
+		if !strings.Contains(buf.String(), test.output) {
+			t.Errorf("Expecting output of %v to contain %q - %s", test.args, test.output, buf.String())
+		}
+	}
+
+	var badDefaults = []struct {
+		config Config
+		field  string
+		typ    string
+		value  string
+	}{
+		{&BadDefaultConf{}, "Port", "int", "eighty"},
+		{&BadDurationDefaultConf{}, "Wait", "fortio.Duration", "soon"},
+	}
+	for _, test := range badDefaults {
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.SetLogger(EmptyLogger{})
+		err := cm.load(test.config, false)
+		perr, ok := err.(*DefaultParseError)
+		if !ok || perr.Field != test.field || perr.Type != test.typ || perr.Value != test.value {
+			t.Errorf("Expecting DefaultParseError for %s, got %v", test.field, err)
+		}
+	}
+}
+
+type DBConf struct {
+	Host string `config:"default=localhost;usage=Database host"`
+	Port int    `config:"default=5432;usage=Database port"`
+	User string `config:";usage=Database user"`
+}
+
+type NestedConf struct {
+	Conf
+	Primary DBConf
+	Replica DBConf `config:"prefix=replica"`
+}
+
+func TestNestedKeys(t *testing.T) {
+	os.Setenv("FORTIO_TEST_REPLICA_USER", "reader")
+	defer os.Unsetenv("FORTIO_TEST_REPLICA_USER")
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "primary:\n  port: 6000\nreplica:\n  host: replica\n")
+
+	c := &NestedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", path, "--primary.host", "primary", "--name", "nested"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if c.Name != "nested" || c.Primary.Host != "primary" || c.Primary.Port != 6000 || c.Primary.User != "" {
+		t.Errorf("Primary is not loaded correctly - %+v", c.Primary)
+	}
+	if c.Replica.Host != "replica" || c.Replica.Port != 5432 || c.Replica.User != "reader" {
+		t.Errorf("Replica is not loaded correctly - %+v", c.Replica)
+	}
+
+	provenance := map[string]Provenance{}
+	for _, p := range cm.Provenance() {
+		provenance[p.Field] = p
+	}
+	if p := provenance["Primary.Host"]; p.Description() != "flag --primary.host" {
+		t.Errorf("Expecting Primary.Host from flag --primary.host, got %+v", p)
+	}
+	if p := provenance["Primary.Port"]; p.Description() != "file "+path+":2" {
+		t.Errorf("Expecting Primary.Port from file line 2, got %+v", p)
+	}
+	if p := provenance["Replica.User"]; p.Description() != "env FORTIO_TEST_REPLICA_USER" {
+		t.Errorf("Expecting Replica.User from env FORTIO_TEST_REPLICA_USER, got %+v", p)
+	}
+}
+
+type tlsConf struct {
+	CertFile string `config:"default=cert.pem;usage=TLS certificate file"`
+	keyFile  string
+}
+
+type EmbeddedConf struct {
+	Conf
+	tlsConf
+}
+
+func TestUnexportedEmbeddedStruct(t *testing.T) {
+	t.Parallel()
+
+	c := &EmbeddedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--certFile", "server.pem"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.CertFile != "server.pem" || c.keyFile != "" {
+		t.Errorf("Expecting exported fields of unexported embedded structs to be set, got %+v", c.tlsConf)
+	}
+}
+
+type CollidingConf struct {
+	Conf
+	DB    DBConf `config:"prefix=cache"`
+	Cache DBConf
+}
+
+type EnvCollidingConf struct {
+	Conf
+	DBHost string `config:"usage=Database host"`
+	DB     DBConf
+}
+
+func TestNestedKeyCollision(t *testing.T) {
+	t.Parallel()
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	err := cm.load(&CollidingConf{}, false)
+	if err == nil || !strings.Contains(err.Error(), "cache.host") {
+		t.Errorf("Expecting collision error for cache.host, got %v", err)
+	}
+
+	var reserved = []struct {
+		config Config
+		err    string
+	}{
+		{&struct {
+			Conf
+			Profile string `config:"usage=Profile of the user"`
+		}{}, "config key profile of Profile collides with the built-in --profile flag"},
+		{&struct {
+			Conf
+			Config string `config:"usage=Config"`
+		}{}, "config key config of Config collides with the built-in --config flag"},
+	}
+	for _, test := range reserved {
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.SetLogger(EmptyLogger{})
+		if err := cm.load(test.config, false); err == nil || err.Error() != test.err {
+			t.Errorf("Expecting collision error %q, got %v", test.err, err)
+		}
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	err = cm.load(&EnvCollidingConf{}, false)
+	expected := "env variable FORTIO_TEST_DB_HOST of DB.Host collides with DBHost"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expecting collision error %q, got %v", expected, err)
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	os.Setenv("FORTIO_TEST_NAME", "prefixed")
+	defer os.Unsetenv("FORTIO_TEST_NAME")
+	os.Setenv("FORTIO_TEST_OTHER_NUMBER", "7")
+	defer os.Unsetenv("FORTIO_TEST_OTHER_NUMBER")
+
+	c := &ProvenanceConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "prefixed" || c.Number != -10 {
+		t.Errorf("Expecting env variables prefixed with app name to be loaded - %s %d", c.Name, c.Number)
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Host" && p.Env != "FORTIO_TEST_PROVENANCE_HOST" {
+			t.Errorf("Expecting env tag to opt out of prefix, got %s", p.Env)
+		}
+	}
+
+	c = &ProvenanceConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetEnvPrefix("FORTIO_TEST_OTHER")
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "my name" || c.Number != 7 {
+		t.Errorf("Expecting env variables with custom prefix to be loaded - %s %d", c.Name, c.Number)
+	}
+}
+
+type ProfileConf struct {
+	Conf
+	Replicas int    `config:"default=1;default.prod=3;usage=Replicas"`
+	LogLevel string `config:"default=debug;default.prod=warn;usage=Log level"`
+	Region   string `config:"default.prod=us;usage=Region"`
+}
+
+type BadProfileConf struct {
+	Conf
+	Replicas int `config:"default=1;default.prod=three;usage=Replicas"`
+}
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "app.yaml", "name: base\nlogLevel: info\n")
+	writeConfigFile(t, dir, "app.prod.yaml", "name: prod\n")
+
+	var testCases = []struct {
+		args     []string
+		env      string
+		name     string
+		replicas int
+		logLevel string
+		region   string
+	}{
+		{[]string{"--config", path}, "", "base", 1, "info", ""},
+		{[]string{"--config", path, "--profile", "prod"}, "", "prod", 3, "info", "us"},
+		{[]string{"--profile", "prod"}, "", "my name", 3, "warn", "us"},
+		{[]string{"--config", path}, "prod", "prod", 3, "info", "us"},
+		{[]string{"--config", path}, "staging", "base", 1, "info", ""},
+	}
+	for _, test := range testCases {
+		if test.env != "" {
+			os.Setenv("FORTIO_TEST_PROFILE", test.env)
+		}
+		c := &ProfileConf{}
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.rootCmd.SetArgs(test.args)
+		err := cm.load(c, true)
+		os.Unsetenv("FORTIO_TEST_PROFILE")
+		if err != nil {
+			t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+		}
+		if c.Name != test.name || c.Replicas != test.replicas || c.LogLevel != test.logLevel || c.Region != test.region {
+			t.Errorf("Expecting %v with profile %s to load %s %d %s %s, got %s %d %s %s", test.args, test.env,
+				test.name, test.replicas, test.logLevel, test.region, c.Name, c.Replicas, c.LogLevel, c.Region)
+		}
+		for _, p := range cm.Provenance() {
+			if p.Field == "Region" && (p.Source == SourceDefault) != (test.region != "") {
+				t.Errorf("Expecting Region source to follow profile default, got %+v", p)
+			}
+		}
+	}
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	if _, ok := cm.load(&BadProfileConf{}, true).(*DefaultParseError); !ok {
+		t.Errorf("Expecting DefaultParseError for invalid profile default")
+	}
+}
+
+type EnvFileConf struct {
+	Conf
+	Password Secret `config:"usage=Password"`
+	Token    string `config:"nofile;usage=Token"`
+	User     string `config:"default=admin;usage=User"`
+	Key      string `config:"usage=Key"`
+	KeyFile  string `config:"usage=Key file"`
+}
+
+func TestEnvFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	password := writeConfigFile(t, dir, "password", "s3${cr}et\n")
+	writeConfigFile(t, dir, "token", "token")
+	user := writeConfigFile(t, dir, "user", "root\r\n")
+
+	env := map[string]string{
+		"FORTIO_TEST_PASSWORD_FILE": password,
+		"FORTIO_TEST_TOKEN_FILE":    filepath.Join(dir, "token"),
+		"FORTIO_TEST_USER_FILE":     user,
+		"FORTIO_TEST_KEY_FILE":      "/etc/key.pem",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	c := &EnvFileConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--user", "flag"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Password.Value() != "s3${cr}et" {
+		t.Errorf("Expecting password read as is from file without trailing newline, got %q", c.Password.Value())
+	}
+	if c.Token != "" {
+		t.Errorf("Expecting nofile to disable the _FILE env variable, got %q", c.Token)
+	}
+	if c.User != "flag" {
+		t.Errorf("Expecting flag to win over the _FILE env variable, got %q", c.User)
+	}
+	if c.Key != "" || c.KeyFile != "/etc/key.pem" {
+		t.Errorf("Expecting env variable of KeyFile not to be read as file of Key, got %q %q", c.Key, c.KeyFile)
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Password" && p.Description() != "env FORTIO_TEST_PASSWORD_FILE" {
+			t.Errorf("Expecting password from env FORTIO_TEST_PASSWORD_FILE, got %s", p.Description())
+		}
+	}
+
+	c = &EnvFileConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(c, true); err != nil || c.User != "root" {
+		t.Errorf("Expecting user read from file without trailing newline, got %q - %v", c.User, err)
+	}
+
+	os.Setenv("FORTIO_TEST_USER", "env")
+	c = &EnvFileConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	err = cm.load(c, true)
+	os.Unsetenv("FORTIO_TEST_USER")
+	if err != nil || c.User != "env" {
+		t.Errorf("Expecting env variable to win over its _FILE env variable, got %q - %v", c.User, err)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetEnvFileSizeLimit(4)
+	cm.rootCmd.SetArgs([]string{})
+	err = cm.load(&EnvFileConf{}, true)
+	if _, ok := err.(*EnvFileError); !ok || !strings.Contains(err.Error(), "limit of 4 bytes") {
+		t.Errorf("Expecting EnvFileError for file over the size limit, got %v", err)
+	}
+
+	os.Setenv("FORTIO_TEST_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	err = cm.load(&EnvFileConf{}, true)
+	if err == nil || !strings.Contains(err.Error(), "FORTIO_TEST_PASSWORD_FILE") {
+		t.Errorf("Expecting error naming FORTIO_TEST_PASSWORD_FILE for missing file, got %v", err)
+	}
 }
@@ -0,0 +1,102 @@
+package fortio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirectoryConfigLoader is config loader that reads config values from a
+// directory with one file per config key, like a mounted Kubernetes
+// ConfigMap or Secret. The file name is the config key and the file content,
+// without trailing newline, its value. Hidden entries like the ..data symlink
+// and timestamped directories of ConfigMaps are skipped, while the key
+// symlinks pointing into them are followed.
+type DirectoryConfigLoader struct {
+	// Path is the directory to read
+	Path string
+	// Separator splits file names into nested keys, like db__host for
+	// db.host with "__". File names are split on "." when empty.
+	Separator string
+
+	configStore
+}
+
+// Load will read a config value from every file of the directory and make
+// them available to the config fields, with lower precedence than env
+// variables and flags
+func (d *DirectoryConfigLoader) Load(config Config) error {
+	entries, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read config directory %s - %v", d.Path, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	values := map[string]interface{}{}
+	store := d.values()
+	for _, name := range names {
+		path := filepath.Join(d.Path, name)
+		// follows the symlinks of ConfigMaps
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("unable to read config file %s - %v", path, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read config file %s - %v", path, err)
+		}
+
+		key := d.key(name)
+		if err := setNested(values, key, strings.TrimRight(string(data), "\r\n")); err != nil {
+			return fmt.Errorf("unable to read config file %s - %v", path, err)
+		}
+		store.origins[key] = origin{document: path}
+	}
+	if err := store.MergeConfigMap(values); err != nil {
+		return fmt.Errorf("unable to merge config from %s - %v", d.Path, err)
+	}
+	return nil
+}
+
+// key returns the config key of the file name
+func (d *DirectoryConfigLoader) key(name string) string {
+	separator := d.Separator
+	if separator == "" {
+		separator = "."
+	}
+	return strings.ToLower(strings.Replace(name, separator, ".", -1))
+}
+
+// setNested sets the value of the nested key like db.host in values
+func setNested(values map[string]interface{}, key string, value string) error {
+	segments := strings.Split(key, ".")
+	for i, segment := range segments[:len(segments)-1] {
+		nested, ok := values[segment].(map[string]interface{})
+		if !ok {
+			if _, exists := values[segment]; exists {
+				return fmt.Errorf("key %s is both a value and a section", strings.Join(segments[:i+1], "."))
+			}
+			nested = map[string]interface{}{}
+			values[segment] = nested
+		}
+		values = nested
+	}
+	name := segments[len(segments)-1]
+	if _, ok := values[name].(map[string]interface{}); ok {
+		return fmt.Errorf("key %s is both a value and a section", key)
+	}
+	values[name] = value
+	return nil
+}
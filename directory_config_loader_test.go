@@ -0,0 +1,124 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigMap writes values into dir the way a mounted ConfigMap is
+// updated: a new timestamped directory swapped in by renaming ..data
+func writeConfigMap(t *testing.T, dir, version string, values map[string]string) {
+	data := filepath.Join(dir, "..data")
+	timestamped := filepath.Join(dir, "..2024_01_01_"+version)
+	if err := os.Mkdir(timestamped, 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, value := range values {
+		writeConfigFile(t, timestamped, name, value)
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join("..data", name), link); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(timestamped), tmp); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Rename(tmp, data); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestDirectoryConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	writeConfigMap(t, dir, "1", map[string]string{
+		"name":          "from dir\n",
+		"number":        "42",
+		"primary__host": "db.example.com\n",
+		"replica__port": "6543",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	c := &NestedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test", &DirectoryConfigLoader{Path: dir, Separator: "__"})
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "from dir" || c.Number != 42 {
+		t.Errorf("Expecting values from directory, got %s %d", c.Name, c.Number)
+	}
+	if c.Primary.Host != "db.example.com" || c.Replica.Port != 6543 || c.Primary.Port != 5432 {
+		t.Errorf("Expecting nested keys split on separator, got %+v %+v", c.Primary, c.Replica)
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Primary.Host" && p.Description() != "file "+filepath.Join(dir, "primary__host") {
+			t.Errorf("Expecting Primary.Host from its file, got %s", p.Description())
+		}
+	}
+
+	changed := make(chan Config, 10)
+	cm.OnChange(func(old, new Config) {
+		changed <- new
+	})
+	if err := cm.Watch(WatchOptions{DisableSignal: true}); err != nil {
+		t.Fatalf("Watching config not supposed to fail - %s", err.Error())
+	}
+	defer cm.StopWatch()
+
+	writeConfigMap(t, dir, "2", map[string]string{
+		"name":          "swapped",
+		"number":        "7",
+		"primary__host": "other.example.com",
+		"replica__port": "6543",
+	})
+	select {
+	case config := <-changed:
+		if c := config.(*NestedConf); c.Name != "swapped" || c.Number != 7 || c.Primary.Host != "other.example.com" {
+			t.Errorf("Config is not reloaded correctly on ..data swap - %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Config is not reloaded on ..data swap")
+	}
+}
+
+func TestDirectoryConfigLoaderErrors(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	writeConfigFile(t, dir, "primary", "db")
+	writeConfigFile(t, dir, "primary.host", "db.example.com")
+
+	var testCases = []struct {
+		path string
+		err  string
+	}{
+		{filepath.Join(dir, "missing"), "unable to read config directory " + filepath.Join(dir, "missing")},
+		{dir, "unable to read config file " + filepath.Join(dir, "primary.host") +
+			" - key primary is both a value and a section"},
+	}
+	for _, test := range testCases {
+		cm := NewConfigManager("fortio-test", "My Fortio test", &DirectoryConfigLoader{Path: test.path})
+		err := cm.load(&NestedConf{}, false)
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("Expecting error %q, got %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package fortio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// docsRow is the reference documentation of a config field
+type docsRow struct {
+	flag, env, key, typ, defaultValue, required, usage string
+}
+
+// Docs writes reference documentation of config in given format, a markdown
+// table or a man page, listing the flag, env variable, file key, type,
+// default, whether it is required and usage of every field
+func (cm *Manager) Docs(w io.Writer, config Config, format string) error {
+	fields, err := cm.configFields(config)
+	if err != nil {
+		return err
+	}
+	var rows []docsRow
+	for _, f := range orderedFields(fields) {
+		row := docsRow{
+			flag:         "--" + f.key,
+			env:          f.envName,
+			key:          f.key,
+			typ:          fieldType(f.addr).String(),
+			defaultValue: f.defaultValue,
+			required:     "no",
+			usage:        f.description,
+		}
+		if f.secret && row.defaultValue != "" {
+			row.defaultValue = redacted
+		}
+		if f.required {
+			row.required = "yes"
+		}
+		rows = append(rows, row)
+	}
+
+	switch format {
+	case "markdown", "md":
+		cm.writeMarkdownDocs(w, rows)
+	case "man":
+		cm.writeManDocs(w, rows)
+	default:
+		return fmt.Errorf("unsupported docs format %q, expecting markdown or man", format)
+	}
+	return nil
+}
+
+func (cm *Manager) writeMarkdownDocs(w io.Writer, rows []docsRow) {
+	code := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "`" + s + "`"
+	}
+	cell := func(s string) string {
+		return strings.Replace(s, "|", `\|`, -1)
+	}
+
+	fmt.Fprintf(w, "# %s\n\n", cm.rootCmd.Name())
+	if cm.rootCmd.Short != "" {
+		fmt.Fprintf(w, "%s\n\n", cm.rootCmd.Short)
+	}
+	fmt.Fprintln(w, "| Flag | Env | Key | Type | Default | Required | Usage |")
+	fmt.Fprintln(w, "|------|-----|-----|------|---------|----------|-------|")
+	for _, r := range rows {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n", code(r.flag), code(r.env), code(r.key),
+			cell(r.typ), cell(code(r.defaultValue)), r.required, cell(r.usage))
+	}
+}
+
+func (cm *Manager) writeManDocs(w io.Writer, rows []docsRow) {
+	name := cm.rootCmd.Name()
+	fmt.Fprintf(w, ".TH %s 1\n", roff(strings.ToUpper(name)))
+	fmt.Fprintln(w, ".SH NAME")
+	if cm.rootCmd.Short != "" {
+		fmt.Fprintf(w, "%s \\- %s\n", roff(name), roff(cm.rootCmd.Short))
+	} else {
+		fmt.Fprintln(w, roff(name))
+	}
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintf(w, ".B %s\n[\\fIOPTIONS\\fR]\n", roff(name))
+
+	fmt.Fprintln(w, ".SH OPTIONS")
+	for _, r := range rows {
+		fmt.Fprintf(w, ".TP\n.B %s \\fI%s\\fR\n", roff(r.flag), roff(r.typ))
+		if r.usage != "" {
+			fmt.Fprintln(w, roff(r.usage))
+			fmt.Fprintln(w, ".br")
+		}
+		details := []string{"Key: " + r.key}
+		if r.env != "" {
+			details = append(details, "Env: "+r.env)
+		}
+		if r.defaultValue != "" {
+			details = append(details, "Default: "+r.defaultValue)
+		}
+		if r.required == "yes" {
+			details = append(details, "Required")
+		}
+		fmt.Fprintln(w, roff(strings.Join(details, ", ")))
+	}
+
+	fmt.Fprintln(w, ".SH ENVIRONMENT")
+	for _, r := range rows {
+		if r.env != "" {
+			fmt.Fprintf(w, ".TP\n.B %s\nSets %s\n", roff(r.env), roff(r.flag))
+		}
+	}
+}
+
+// roff escapes text for a man page
+func roff(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// docsCommand returns the docs command that prints reference documentation
+// of the config instead of running the app
+func (cm *Manager) docsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Print reference documentation of the config as markdown or man page",
+		Run: func(cmd *cobra.Command, args []string) {
+			cm.commandErr = ErrDocsRequested
+		},
+	}
+	cmd.Flags().StringVar(&cm.docsFormat, "format", "markdown", "format of the documentation (markdown or man)")
+	return cmd
+}
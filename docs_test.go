@@ -0,0 +1,77 @@
+package fortio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type DocsConf struct {
+	Conf
+	Mode     string `config:"required;oneof=a|b;usage=Mode, a|b"`
+	Password Secret `config:"default=hunter2;usage=Password"`
+	Primary  DBConf
+	Port     *int `config:";usage=-port to listen on"`
+}
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	buf := &bytes.Buffer{}
+	if err := cm.Docs(buf, &DocsConf{}, "markdown"); err != nil {
+		t.Fatal(err.Error())
+	}
+	md := buf.String()
+	for _, row := range []string{
+		"| `--name` | `FORTIO_TEST_NAME` | `name` | string | `my name` | no | Give me a name |",
+		"| `--mode` | `FORTIO_TEST_MODE` | `mode` | string |  | yes | Mode, a\\|b |",
+		"| `--password` | `FORTIO_TEST_PASSWORD` | `password` | fortio.Secret | `***` | no | Password |",
+		"| `--primary.port` | `FORTIO_TEST_PRIMARY_PORT` | `primary.port` | int | `5432` | no | Database port |",
+	} {
+		if !strings.Contains(md, row+"\n") {
+			t.Errorf("Expecting markdown docs to contain %s - %s", row, md)
+		}
+	}
+	if strings.Index(md, "`--name`") > strings.Index(md, "`--mode`") {
+		t.Errorf("Expecting docs to list fields in struct order - %s", md)
+	}
+
+	buf.Reset()
+	if err := cm.Docs(buf, &DocsConf{}, "man"); err != nil {
+		t.Fatal(err.Error())
+	}
+	man := buf.String()
+	for _, line := range []string{
+		".TH FORTIO\\-TEST 1\n",
+		".B \\-\\-primary.port \\fIint\\fR\nDatabase port\n.br\nKey: primary.port, Env: FORTIO_TEST_PRIMARY_PORT, Default: 5432\n",
+		"\\-port to listen on\n",
+		".B FORTIO_TEST_MODE\nSets \\-\\-mode\n",
+	} {
+		if !strings.Contains(man, line) {
+			t.Errorf("Expecting man page to contain %q - %s", line, man)
+		}
+	}
+	if strings.Contains(md+man, "hunter2") {
+		t.Errorf("Expecting secret defaults to be redacted in docs")
+	}
+
+	if err := cm.Docs(buf, &DocsConf{}, "html"); err == nil {
+		t.Errorf("Expecting error for unsupported format")
+	}
+}
+
+func TestDocsCommand(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"docs", "--format", "man"})
+	cm.rootCmd.SetOutput(buf)
+	if err := cm.load(&DocsConf{}, true); err != ErrDocsRequested {
+		t.Errorf("Expecting ErrDocsRequested, got %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), ".TH FORTIO\\-TEST 1\n") {
+		t.Errorf("Expecting docs command to print a man page - %s", buf.String())
+	}
+}
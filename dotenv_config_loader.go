@@ -0,0 +1,188 @@
+package fortio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dotenvNameRegex matches the names of variables in .env files
+var dotenvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// DotenvConfigLoader is config loader that reads env variables from .env
+// files for local development. Variables of .env files take the place of
+// env variables of the process without changing the process environment, so
+// flags and env variables of the process win over them, and they win over
+// config files. Variables not bound to a config field are ignored, and so are
+// empty values, like empty env variables.
+type DotenvConfigLoader struct {
+	// Paths are the .env files to read, later files overriding variables of
+	// earlier ones. Defaults to .env in the working directory.
+	Paths []string
+	// Required fails loading when a file doesn't exist, missing files are
+	// skipped otherwise
+	Required bool
+
+	configStore
+}
+
+// dotenvVar is a variable of a .env file
+type dotenvVar struct {
+	name  string
+	value string
+}
+
+// Load will read all .env files and make the variables bound to config fields
+// available to them
+func (d *DotenvConfigLoader) Load(config Config) error {
+	paths := d.Paths
+	if len(paths) == 0 {
+		paths = []string{".env"}
+	}
+	store := d.values()
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) && !d.Required {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read env file %s - %v", path, err)
+		}
+		vars, err := parseDotenv(string(data))
+		if err != nil {
+			return fmt.Errorf("unable to parse env file %s - %v", path, err)
+		}
+		for _, v := range vars {
+			key, ok := store.envKeys[v.name]
+			if !ok || v.value == "" || envIsSet(v.name) || store.flagChanged(key) {
+				continue
+			}
+			store.Set(key, v.value)
+			store.dotenv[v.name] = path
+		}
+	}
+	return nil
+}
+
+// envIsSet reports whether the process sets the env variable name, directly
+// or through its _FILE env variable
+func envIsSet(name string) bool {
+	return os.Getenv(name) != "" || os.Getenv(name+envFileSuffix) != ""
+}
+
+// parseDotenv parses the NAME=value lines of a .env file. Lines may start with
+// export, and # starts a comment at the start of a line or after whitespace
+// following an unquoted value. Single quoted values are taken as is, double
+// quoted values support \n, \r, \t, \", \\ and \$ escapes, and both may span
+// multiple lines.
+func parseDotenv(data string) ([]dotenvVar, error) {
+	var vars []dotenvVar
+	data = strings.TrimPrefix(strings.Replace(data, "\r\n", "\n", -1), "\ufeff")
+	line := 1
+	for len(data) > 0 {
+		start := line
+		text, rest := nextLine(data)
+		data = rest
+		line++
+
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "export ") || strings.HasPrefix(text, "export\t") {
+			text = strings.TrimSpace(text[len("export"):])
+		}
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing = at line %d", start)
+		}
+		name := strings.TrimSpace(text[:eq])
+		if !dotenvNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q at line %d", name, start)
+		}
+		value := strings.TrimLeft(text[eq+1:], " \t")
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			// quoted values continue on the following lines until closed
+			quote := value[0]
+			raw := value[1:]
+			end := closingQuote(raw, quote)
+			for end < 0 && len(data) > 0 {
+				next, rest := nextLine(data)
+				data = rest
+				line++
+				raw += "\n" + next
+				end = closingQuote(raw, quote)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing quote of %s at line %d", name, start)
+			}
+			if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("unexpected %q after quoted value of %s at line %d", rest, name, start)
+			}
+			value = raw[:end]
+			if quote == '"' {
+				value = unescapeDotenv(value)
+			}
+		} else {
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			} else if i := strings.Index(value, "\t#"); i >= 0 {
+				value = value[:i]
+			}
+			value = strings.TrimSpace(value)
+		}
+		vars = append(vars, dotenvVar{name: name, value: value})
+	}
+	return vars, nil
+}
+
+// nextLine splits the first line off data
+func nextLine(data string) (string, string) {
+	if i := strings.IndexByte(data, '\n'); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, ""
+}
+
+// closingQuote returns the index of the quote closing s, or -1 if s is not
+// closed. Double quotes can be escaped with a backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeDotenv replaces the escapes of a double quoted .env value
+func unescapeDotenv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
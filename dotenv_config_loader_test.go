@@ -0,0 +1,116 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+
+	data := "\ufeff# comment\r\n" +
+		"PLAIN=value\n" +
+		"  export EXPORTED = spaced value   # trailing comment\n" +
+		"HASH=a#b\n" +
+		"EMPTY=\n" +
+		"SINGLE='literal \\n ${x} # not a comment'\n" +
+		"DOUBLE=\"tab\\tquote\\\" dollar\\$ newline\\n\" # comment\n" +
+		"MULTI=\"first\n" +
+		"second\"\n" +
+		"KEY='-----BEGIN-----\n" +
+		"abc\n" +
+		"-----END-----'\n" +
+		"LAST=no newline"
+	expected := []dotenvVar{
+		{"PLAIN", "value"},
+		{"EXPORTED", "spaced value"},
+		{"HASH", "a#b"},
+		{"EMPTY", ""},
+		{"SINGLE", `literal \n ${x} # not a comment`},
+		{"DOUBLE", "tab\tquote\" dollar$ newline\n"},
+		{"MULTI", "first\nsecond"},
+		{"KEY", "-----BEGIN-----\nabc\n-----END-----"},
+		{"LAST", "no newline"},
+	}
+	vars, err := parseDotenv(data)
+	if err != nil {
+		t.Fatalf("Parsing .env not supposed to fail - %s", err.Error())
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expecting %v, got %v", expected, vars)
+	}
+
+	var testCases = []struct {
+		data string
+		err  string
+	}{
+		{"A=1\nB\n", "missing = at line 2"},
+		{"A=1\n1A=2\n", `invalid variable name "1A" at line 2`},
+		{"A=1\nB=\"open\nstill open\n", "missing closing quote of B at line 2"},
+		{"A='x' y\n", `unexpected "y" after quoted value of A at line 1`},
+	}
+	for _, test := range testCases {
+		_, err := parseDotenv(test.data)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Expecting error %q for %q, got %v", test.err, test.data, err)
+		}
+	}
+}
+
+func TestDotenvConfigLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, ".env",
+		"FORTIO_TEST_NAME=dotenv\nFORTIO_TEST_NUMBER=7\nFORTIO_TEST_INT8=3\nFORTIO_TEST_UNKNOWN=x\n")
+	local := writeConfigFile(t, dir, ".env.local", "FORTIO_TEST_INT8=4\nFORTIO_TEST_INT32=\n")
+	config := writeConfigFile(t, dir, "conf.yaml", "name: file\nint32: 5\n")
+
+	os.Setenv("FORTIO_TEST_NUMBER", "9")
+	defer os.Unsetenv("FORTIO_TEST_NUMBER")
+
+	c := &Conf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test",
+		&FileConfigLoader{Paths: []string{config}},
+		&DotenvConfigLoader{Paths: []string{path, local, filepath.Join(dir, "missing")}})
+	cm.rootCmd.SetArgs([]string{"--int8", "1"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "dotenv" || c.Int32 != 5 {
+		t.Errorf("Expecting .env to win over config files except for empty values, got %s %d", c.Name, c.Int32)
+	}
+	if c.Number != 9 {
+		t.Errorf("Expecting process env to win over .env, got %d", c.Number)
+	}
+	if c.Int8 != 1 {
+		t.Errorf("Expecting flag to win over .env, got %d", c.Int8)
+	}
+	if _, ok := os.LookupEnv("FORTIO_TEST_NAME"); ok {
+		t.Errorf("Expecting .env not to change the process environment")
+	}
+	for _, p := range cm.Provenance() {
+		if p.Field == "Name" && p.Description() != "env FORTIO_TEST_NAME from "+path {
+			t.Errorf("Expecting name from .env, got %s", p.Description())
+		}
+	}
+
+	c = &Conf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test", &DotenvConfigLoader{Paths: []string{path, local}})
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(c, true); err != nil || c.Int8 != 4 {
+		t.Errorf("Expecting later .env files to override earlier ones, got %d - %v", c.Int8, err)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test",
+		&DotenvConfigLoader{Paths: []string{filepath.Join(dir, "missing")}, Required: true})
+	if err := cm.load(&Conf{}, false); err == nil || !strings.Contains(err.Error(), "unable to read env file") {
+		t.Errorf("Expecting error for missing required .env file, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package fortio
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// envFileSuffix is appended to the env variable of a field to name the env
+// variable holding the path of a file with its value, like PASSWORD_FILE
+const envFileSuffix = "_FILE"
+
+// defaultEnvFileSizeLimit is the largest file read through a _FILE env
+// variable unless changed with SetEnvFileSizeLimit
+const defaultEnvFileSizeLimit = 1 << 20
+
+// SetEnvFileSizeLimit sets the largest file in bytes that can be read through
+// a <ENV>_FILE env variable, 1 MiB by default
+func (cm *Manager) SetEnvFileSizeLimit(limit int64) {
+	cm.envFileSizeLimit = limit
+}
+
+// envFileName returns the _FILE env variable of f, or an empty string if f
+// has none because it is not bound to an env variable, is tagged nofile, or
+// the name is the env variable of another field
+func envFileName(f field, fields map[string]field) string {
+	if f.namespace != environmentVariable || f.envName == "" || f.noFile {
+		return ""
+	}
+	name := f.envName + envFileSuffix
+	for _, other := range fields {
+		if other.envName == name {
+			return ""
+		}
+	}
+	return name
+}
+
+// loadEnvFiles sets the value of each field whose _FILE env variable is set to
+// the content of that file. The value takes the place of the env variable, so
+// a flag or the env variable itself still win over it. The content is used as
+// is, references like ${name} in it are not resolved.
+func (cm *Manager) loadEnvFiles() error {
+	for key, f := range cm.fields {
+		name := envFileName(f, cm.fields)
+		if name == "" || os.Getenv(name) == "" || os.Getenv(f.envName) != "" {
+			continue
+		}
+		path := os.Getenv(name)
+		if cm.store.flagChanged(key) {
+			continue
+		}
+		value, err := cm.readEnvFile(path)
+		if err != nil {
+			return &EnvFileError{Field: f.name, Env: name, Path: path, Err: err}
+		}
+		cm.store.Set(key, escapeReferences(value))
+	}
+	return nil
+}
+
+// readEnvFile reads the file at path without its trailing newline
+func (cm *Manager) readEnvFile(path string) (string, error) {
+	limit := cm.envFileSizeLimit
+	if limit <= 0 {
+		limit = defaultEnvFileSizeLimit
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > limit {
+		return "", fmt.Errorf("file of %d bytes exceeds the limit of %d bytes", info.Size(), limit)
+	}
+	// the file may grow or be a pipe, so don't rely on its size alone
+	data, err := ioutil.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > limit {
+		return "", fmt.Errorf("file exceeds the limit of %d bytes", limit)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
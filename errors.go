@@ -0,0 +1,70 @@
+package fortio
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrHelpRequested is returned by Load when help was requested with the
+	// help command or --help flag, after help was printed
+	ErrHelpRequested = errors.New("help requested")
+
+	// ErrVersionRequested is returned by Load when the version command was
+	// run, after the version was printed
+	ErrVersionRequested = errors.New("version requested")
+
+	// ErrExplainRequested is returned by Load when the explain command was
+	// run, after the provenance of the config values was printed
+	ErrExplainRequested = errors.New("explain requested")
+
+	// ErrSchemaRequested is returned by Load when the schema command was run,
+	// after the JSON Schema of the config was printed
+	ErrSchemaRequested = errors.New("schema requested")
+
+	// ErrSampleConfigRequested is returned by Load when the sample-config
+	// command was run, after the sample config file was printed
+	ErrSampleConfigRequested = errors.New("sample config requested")
+
+	// ErrDocsRequested is returned by Load when the docs command was run,
+	// after the reference documentation of the config was printed
+	ErrDocsRequested = errors.New("docs requested")
+)
+
+// DefaultParseError is returned by Load when the default= value in the config
+// tag of a field can't be parsed as the type of the field
+type DefaultParseError struct {
+	Field string
+	Type  string
+	Value string
+	Err   error
+}
+
+func (e *DefaultParseError) Error() string {
+	return fmt.Sprintf("default specified for %s is not a %s: %q - %v", e.Field, e.Type, e.Value, e.Err)
+}
+
+// EnvFileError is returned by Load when the file named by the _FILE env
+// variable of a field can't be read
+type EnvFileError struct {
+	Field string
+	Env   string
+	Path  string
+	Err   error
+}
+
+func (e *EnvFileError) Error() string {
+	return fmt.Sprintf("unable to read %s from %s=%s - %v", e.Field, e.Env, e.Path, e.Err)
+}
+
+// TagError is returned by Load when the config tag of a field is malformed or
+// has an unknown key
+type TagError struct {
+	Field string
+	Tag   string
+	Err   error
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("invalid config tag of %s: %q - %v", e.Field, e.Tag, e.Err)
+}
@@ -5,26 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"time"
 
 	"github.com/CrowdStrike/fortio"
 	"gopkg.in/yaml.v2"
 )
 
 type ExampleConfig struct {
-	Timeout  fortio.Duration  `config:"env=TIMEOUT;default=100ms;usage=Timeout for service" json:"timeout"`
-	Name     string           `config:"default=test;usage=Name of service" json:"name"`
+	Timeout  fortio.Duration  `config:"env=TIMEOUT;default=100ms;max=100ms;usage=Timeout for service" json:"timeout"`
+	Name     string           `config:"default=test;nonempty;usage=Name of service" json:"name"`
 	Registry Registry         `config:";default=./registry.json;usage="`
 	Map      fortio.MapObject `config:";default={\"a\":\"b\"};usage="`
 }
 
-// Validates assigned config values
+// Validates assigned config values, constraints that can be expressed as
+// config tags are already checked by the config manager
 func (ec *ExampleConfig) Validate() error {
-	if ec.Timeout.Duration > time.Duration(100)*time.Millisecond {
-		return errors.New("timeout can't be greater than 100ms")
-	}
-	if ec.Name == "" {
-		return errors.New("name can't be empty")
+	if ec.Registry.Name != "" && ec.Registry.RegType == "" {
+		return errors.New("registry type can't be empty")
 	}
 	return nil
 }
@@ -46,16 +43,15 @@ func main() {
 	config := &ExampleConfig{}
 	// Initialize config manager
 	cm := fortio.NewConfigManager("fortio-test", "My Fortio example")
-	// Pass config pointer to be loaded from env variables
-	err := cm.Load(config)
-	if err != nil {
-		panic(err)
-	}
+	// Pass config pointer to be loaded from env variables, exits after
+	// printing help or version
+	cm.LoadOrExit(config)
 
-	fmt.Printf("Loaded config: %+v\n", config)
+	// Redact secrets before printing config
+	fmt.Printf("Loaded config: %+v\n", fortio.Redact(config))
 
 	// validate configs loaded
-	err = config.Validate()
+	err := config.Validate()
 	if err != nil {
 		// handle error
 	}
@@ -80,10 +76,6 @@ func (r *Registry) Set(s string) error {
 	return nil
 }
 
-func (r *Registry) ParseString(s string) error {
-	return r.Set(s)
-}
-
 func (r *Registry) Type() string {
 	return "fortio.registry"
 }
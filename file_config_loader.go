@@ -0,0 +1,173 @@
+package fortio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+)
+
+const (
+	configFlag  = "config"
+	profileFlag = "profile"
+)
+
+// configFileTypes maps supported config file extensions to viper config types
+var configFileTypes = map[string]string{
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".toml": "toml",
+}
+
+// FileConfigLoader is config loader that reads config values from YAML, JSON
+// or TOML files. Files are read from Paths followed by any paths given with the
+// --config flag, later files overriding values of earlier ones. With a
+// --profile like prod, each file like app.yaml is followed by app.prod.yaml
+// if it exists.
+type FileConfigLoader struct {
+	Paths []string
+
+	configStore
+}
+
+// Load will read all config files and make their values available to the
+// config fields, with lower precedence than env variables and flags
+func (f *FileConfigLoader) Load(config Config) error {
+	paths := append([]string{}, f.Paths...)
+	paths = append(paths, f.values().GetStringSlice(configFlag)...)
+	profile := f.values().GetString(profileFlag)
+	for _, path := range paths {
+		if err := f.loadFile(path); err != nil {
+			return err
+		}
+		if profile == "" {
+			continue
+		}
+		if path := profilePath(path, profile); fileExists(path) {
+			if err := f.loadFile(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// profilePath returns the path of the profile file of a config file, like
+// app.prod.yaml for app.yaml
+func profilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (f *FileConfigLoader) loadFile(path string) error {
+	configType, err := configFileType(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s - %v", path, err)
+	}
+	return mergeConfig(f.values(), data, configType, path)
+}
+
+// mergeConfig parses data in given config type and merges it into the config
+// values read so far in store. document names where data came from, like a
+// file path, and is recorded as origin of the config keys in data.
+func mergeConfig(store *configValues, data []byte, configType, document string) error {
+	if configType == "json" {
+		// viper only reports the byte offset of JSON syntax errors
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			if serr, ok := err.(*json.SyntaxError); ok {
+				line := bytes.Count(data[:serr.Offset], []byte("\n")) + 1
+				return fmt.Errorf("unable to parse config from %s at line %d - %v", document, line, err)
+			}
+			return fmt.Errorf("unable to parse config from %s - %v", document, err)
+		}
+	}
+
+	doc := viper.New()
+	doc.SetConfigType(configType)
+	if err := doc.ReadConfig(bytes.NewReader(data)); err != nil {
+		if configType == "toml" {
+			if line := tomlErrorLine(data, err); line > 0 {
+				return fmt.Errorf("unable to parse config from %s at line %d - %v", document, line, err)
+			}
+		}
+		return fmt.Errorf("unable to parse config from %s - %v", document, err)
+	}
+	for _, key := range doc.AllKeys() {
+		if store.appendKeys[key] && store.InConfig(key) {
+			doc.Set(key, append(listValue(store.Get(key)), listValue(doc.Get(key))...))
+		}
+	}
+	if err := store.MergeConfigMap(doc.AllSettings()); err != nil {
+		return fmt.Errorf("unable to merge config from %s - %v", document, err)
+	}
+	for _, key := range doc.AllKeys() {
+		store.origins[key] = origin{document: document, line: keyLine(data, key)}
+	}
+	return nil
+}
+
+// tomlErrorLine returns the line of a TOML parse error, or 0 if unknown. viper
+// passes on the position of syntax errors without reporting it, and errors
+// like duplicate keys have no position, so their line is the first line that
+// makes the document fail with the same error.
+func tomlErrorLine(data []byte, err error) int {
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		line, _ := decodeErr.Position()
+		return line
+	}
+	var prefix []byte
+	for i, line := range bytes.SplitAfter(data, []byte("\n")) {
+		prefix = append(prefix, line...)
+		var v map[string]interface{}
+		if prefixErr := toml.Unmarshal(prefix, &v); prefixErr != nil && strings.Contains(err.Error(), prefixErr.Error()) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// listValue returns the list of a config value, splitting comma separated
+// strings
+func listValue(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case string:
+		var list []interface{}
+		for _, item := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list
+	}
+	return []interface{}{value}
+}
+
+// configFileType detects the config format of a file by its extension
+func configFileType(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	configType, ok := configFileTypes[ext]
+	if !ok {
+		return "", fmt.Errorf("unsupported config file format %q for %s", ext, path)
+	}
+	return configType, nil
+}
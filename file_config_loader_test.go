@@ -0,0 +1,104 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func TestFileConfigLoaderFormats(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	var testCases = []struct {
+		file    string
+		content string
+	}{
+		{"conf.yaml", "name: yaml name\nnumber: 42\narray:\n  - a\n  - b\nduration: 1s\n"},
+		{"conf.json", `{"name": "json name", "number": 42, "array": ["a", "b"], "duration": "1s"}`},
+		{"conf.toml", "name = \"toml name\"\nnumber = 42\narray = [\"a\", \"b\"]\nduration = \"1s\"\n"},
+	}
+
+	for _, test := range testCases {
+		path := writeConfigFile(t, dir, test.file, test.content)
+
+		c := &Conf{}
+		cm := NewConfigManager("fortio-test", "My Fortio test", &FileConfigLoader{Paths: []string{path}})
+		if err := cm.load(c, false); err != nil {
+			t.Fatalf("Config loading from %s not supposed to fail - %s", test.file, err.Error())
+		}
+
+		if c.Name != filepath.Ext(test.file)[1:]+" name" {
+			t.Errorf("Name is not loaded correctly from %s - %s", test.file, c.Name)
+		}
+		if c.Number != 42 {
+			t.Errorf("Number is not loaded correctly from %s - %d", test.file, c.Number)
+		}
+		if len(c.Array) != 2 || c.Array[0] != "a" || c.Array[1] != "b" {
+			t.Errorf("Array is not loaded correctly from %s - %v", test.file, c.Array)
+		}
+		if c.Duration.String() != "1s" {
+			t.Errorf("Duration is not loaded correctly from %s - %v", test.file, c.Duration)
+		}
+		// fields missing from file keep their defaults
+		if c.Int8 != 11 {
+			t.Errorf("Int8 default is not kept for %s", test.file)
+		}
+	}
+}
+
+func TestFileConfigLoaderConfigFlag(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	base := writeConfigFile(t, dir, "base.yaml", "name: base\nnumber: 1\n")
+	override := writeConfigFile(t, dir, "override.json", `{"number": 2}`)
+
+	c := &Conf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", base, "--config", override, "--int8", "5"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	if c.Name != "base" {
+		t.Errorf("Name is not loaded from base file - %s", c.Name)
+	}
+	if c.Number != 2 {
+		t.Errorf("Number is not overridden by later file - %d", c.Number)
+	}
+	if c.Int8 != 5 {
+		t.Errorf("Flag is not taking precedence over files - %d", c.Int8)
+	}
+}
+
+func TestFileConfigLoaderErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"conf.ini", "does-not-exist.yaml"} {
+		loader := &FileConfigLoader{Paths: []string{path}}
+		loader.setStore(newConfigValues())
+		if err := loader.Load(&Conf{}); err == nil {
+			t.Errorf("Expecting error when loading %s", path)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package fortio
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout = 10 * time.Second
+	defaultHTTPBackoff = 500 * time.Millisecond
+	defaultHTTPMaxSize = 1 << 20
+)
+
+// httpContentTypes maps content types of config documents to viper config types
+var httpContentTypes = map[string]string{
+	"application/json":   "json",
+	"application/yaml":   "yaml",
+	"application/x-yaml": "yaml",
+	"text/yaml":          "yaml",
+	"text/x-yaml":        "yaml",
+	"application/toml":   "toml",
+	"text/toml":          "toml",
+}
+
+// HTTPConfigLoader is config loader that fetches a config document over HTTP(S).
+// The document format is taken from Format, the response Content-Type, the URL
+// extension or detected from the content, in that order. Responses are cached
+// by ETag so loading again only transfers the document when it changed.
+type HTTPConfigLoader struct {
+	// URL of the config document
+	URL string
+	// Field if set loads the whole document, without trailing newline, as the
+	// value of the config field with this name instead of parsing it as a
+	// config document
+	Field string
+	// Format forces the document format to one of yaml, json or toml
+	Format string
+	// Headers are added to every request, e.g. for authorization
+	Headers map[string]string
+	// Timeout of a single request, defaults to 10s
+	Timeout time.Duration
+	// MaxSize is the maximum number of bytes of the document, defaults to 1MB
+	MaxSize int64
+	// Retries is the number of times a failed request is retried
+	Retries int
+	// Backoff is the wait before the first retry, doubled for every further
+	// retry, defaults to 500ms
+	Backoff time.Duration
+	// CertFile and KeyFile are the TLS client certificate and key
+	CertFile string
+	KeyFile  string
+	// CAFile is used to verify the server certificate instead of system roots
+	CAFile string
+	// Client replaces the HTTP client built from the settings above
+	Client *http.Client
+
+	httpClient  *http.Client
+	etag        string
+	body        []byte
+	contentType string
+	// logger is the Logger of the Manager, the package Log when used on its own
+	logger Logger
+
+	configStore
+}
+
+// Load will fetch the config document and make its values available to the
+// config fields, with lower precedence than env variables and flags
+func (h *HTTPConfigLoader) Load(config Config) error {
+	if h.URL == "" {
+		return errors.New("url of http config loader can't be empty")
+	}
+	if err := h.fetch(); err != nil {
+		return err
+	}
+
+	if h.Field != "" {
+		value := strings.TrimRight(string(h.body), "\r\n")
+		if err := checkFieldValue(config, h.Field, value); err != nil {
+			return fmt.Errorf("invalid config from %s - %v", h.URL, err)
+		}
+		store := h.values()
+		store.origins[strings.ToLower(h.Field)] = origin{document: h.URL}
+		// nested keys like db.password are merged as nested maps
+		segments := strings.Split(h.Field, ".")
+		var nested interface{} = value
+		for i := len(segments) - 1; i >= 0; i-- {
+			nested = map[string]interface{}{segments[i]: nested}
+		}
+		return store.MergeConfigMap(nested.(map[string]interface{}))
+	}
+	return mergeConfig(h.values(), h.body, h.configType(), h.URL)
+}
+
+// checkFieldValue returns an error if value can't be parsed as the value of
+// the field of config with given key
+func checkFieldValue(config Config, key, value string) error {
+	fields := make(map[string]field)
+	if err := getAllFields(config, fields); err != nil {
+		return err
+	}
+	for k, f := range fields {
+		if !strings.EqualFold(k, key) || fieldType(f.addr).Kind() == reflect.Interface {
+			continue
+		}
+		if err := checkDefault(f.addr, value); err != nil {
+			return fmt.Errorf("can't load %s - %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+// fetch requests the document, retrying failed requests with backoff
+func (h *HTTPConfigLoader) fetch() error {
+	client, err := h.client()
+	if err != nil {
+		return err
+	}
+
+	backoff := h.Backoff
+	if backoff <= 0 {
+		backoff = defaultHTTPBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := h.request(client)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= h.Retries {
+			return fmt.Errorf("unable to fetch config from %s - %v", h.URL, err)
+		}
+		h.log().Debugf("fetching config from %s failed, retrying in %v - %v", h.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (h *HTTPConfigLoader) setLogger(logger Logger) {
+	h.logger = logger
+}
+
+func (h *HTTPConfigLoader) log() Logger {
+	if h.logger == nil {
+		return Log
+	}
+	return h.logger
+}
+
+// request does a single request and reports whether a failed request can be retried
+func (h *HTTPConfigLoader) request(client *http.Client) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, h.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.etag != "" {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && h.body != nil:
+		return false, nil
+	case resp.StatusCode == http.StatusOK:
+		maxSize := h.MaxSize
+		if maxSize <= 0 {
+			maxSize = defaultHTTPMaxSize
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		if err != nil {
+			return true, err
+		}
+		if int64(len(body)) > maxSize {
+			return false, fmt.Errorf("config exceeds max size of %d bytes", maxSize)
+		}
+		h.body = body
+		h.etag = resp.Header.Get("ETag")
+		h.contentType = resp.Header.Get("Content-Type")
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// client returns Client or builds one with the configured timeout and TLS settings
+func (h *HTTPConfigLoader) client() (*http.Client, error) {
+	if h.Client != nil {
+		return h.Client, nil
+	}
+	if h.httpClient != nil {
+		return h.httpClient, nil
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	if h.CertFile != "" || h.CAFile != "" {
+		tlsConfig := &tls.Config{}
+		if h.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(h.CertFile, h.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load tls client certificate - %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		if h.CAFile != "" {
+			ca, err := ioutil.ReadFile(h.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read tls ca file - %v", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in tls ca file %s", h.CAFile)
+			}
+		}
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	h.httpClient = client
+	return client, nil
+}
+
+// configType returns the config type of the fetched document
+func (h *HTTPConfigLoader) configType() string {
+	if h.Format != "" {
+		return h.Format
+	}
+	if mediaType, _, err := mime.ParseMediaType(h.contentType); err == nil {
+		if configType, ok := httpContentTypes[mediaType]; ok {
+			return configType
+		}
+	}
+	if u, err := url.Parse(h.URL); err == nil {
+		if configType, err := configFileType(path.Base(u.Path)); err == nil {
+			return configType
+		}
+	}
+	return detectConfigType(h.body)
+}
@@ -0,0 +1,172 @@
+package fortio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	requests, transfers := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		transfers++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/x-yaml; charset=utf-8")
+		w.Write([]byte("name: remote\nnumber: 3\n"))
+	}))
+	defer server.Close()
+
+	loader := &HTTPConfigLoader{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}
+	for i := 0; i < 2; i++ {
+		c := &Conf{}
+		cm := NewConfigManager("fortio-test", "My Fortio test", loader)
+		if err := cm.load(c, false); err != nil {
+			t.Fatalf("Config loading over http not supposed to fail - %s", err.Error())
+		}
+		if c.Name != "remote" || c.Number != 3 {
+			t.Errorf("Config is not loaded correctly over http - %s %d", c.Name, c.Number)
+		}
+	}
+	if requests != 2 || transfers != 1 {
+		t.Errorf("Expecting second load to be served from ETag cache, got %d requests and %d transfers", requests, transfers)
+	}
+
+	loader = &HTTPConfigLoader{URL: server.URL}
+	loader.setStore(newConfigValues())
+	if err := loader.Load(&Conf{}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expecting unauthorized error, got %v", err)
+	}
+}
+
+func TestHTTPConfigLoaderRetries(t *testing.T) {
+	t.Parallel()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"name": "retried"}`))
+	}))
+	defer server.Close()
+
+	loader := &HTTPConfigLoader{URL: server.URL, Retries: 1, Backoff: time.Millisecond}
+	loader.setStore(newConfigValues())
+	if err := loader.Load(&Conf{}); err == nil {
+		t.Errorf("Expecting error when retries are exhausted")
+	}
+
+	requests = 0
+	c := &Conf{}
+	loader = &HTTPConfigLoader{URL: server.URL, Retries: 2, Backoff: time.Millisecond}
+	logger := &recordingLogger{}
+	cm := NewConfigManager("fortio-test", "My Fortio test", loader)
+	cm.SetLogger(logger)
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading with retries not supposed to fail - %s", err.Error())
+	}
+	if c.Name != "retried" {
+		t.Errorf("Name is not loaded correctly after retries - %s", c.Name)
+	}
+	if len(logger.debugs) != 2 || !strings.Contains(logger.debugs[0], "retrying in 1ms") {
+		t.Errorf("Expecting retries logged through the Manager logger, got %v", logger.debugs)
+	}
+}
+
+type URLConf struct {
+	Conf
+	Remote string `config:"url=http://fortio.test/remote;usage=Remote value"`
+	Port   int    `config:"url=http://fortio.test/port;usage=Remote port"`
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestHTTPConfigLoaderURLTag(t *testing.T) {
+	port, transfers := "42\n", 0
+	defaultTransport := http.DefaultTransport
+	defer func() { http.DefaultTransport = defaultTransport }()
+	http.DefaultTransport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch r.URL.String() {
+		case "http://fortio.test/remote":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				rec.WriteHeader(http.StatusNotModified)
+				break
+			}
+			transfers++
+			rec.Header().Set("ETag", `"v1"`)
+			rec.Write([]byte("remote value\r\n"))
+		case "http://fortio.test/port":
+			rec.Write([]byte(port))
+		default:
+			rec.WriteHeader(http.StatusNotFound)
+		}
+		return rec.Result(), nil
+	})
+
+	c := &URLConf{}
+	logger := &recordingLogger{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(logger)
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	for key, loader := range cm.httpLoaders {
+		if loader.log() != logger {
+			t.Errorf("Expecting loader of url tag of %s to log through the Manager logger", key)
+		}
+	}
+	if c.Remote != "remote value" || c.Port != 42 {
+		t.Errorf("Expecting values from url tag without trailing newline, got %q %d", c.Remote, c.Port)
+	}
+	if c.Name != "my name" {
+		t.Errorf("Name is not loaded correctly next to url tag - %s", c.Name)
+	}
+
+	if err := cm.Reload(); err != nil {
+		t.Fatalf("Config reloading not supposed to fail - %s", err.Error())
+	}
+	if current := cm.Current().(*URLConf); current.Remote != "remote value" || transfers != 1 {
+		t.Errorf("Expecting reload to be served from ETag cache, got %q after %d transfers", current.Remote, transfers)
+	}
+
+	port = "forty-two"
+	err := NewConfigManager("fortio-test", "My Fortio test").load(&URLConf{}, false)
+	if err == nil || !strings.Contains(err.Error(), "can't load Port") {
+		t.Errorf("Expecting error for value from url tag that is not an int, got %v", err)
+	}
+}
+
+func TestHTTPConfigLoaderMaxSize(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("name: too long\n"))
+	}))
+	defer server.Close()
+
+	loader := &HTTPConfigLoader{URL: server.URL, MaxSize: 8}
+	loader.setStore(newConfigValues())
+	if err := loader.Load(&Conf{}); err == nil || !strings.Contains(err.Error(), "exceeds max size of 8 bytes") {
+		t.Errorf("Expecting max size error, got %v", err)
+	}
+}
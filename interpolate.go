@@ -0,0 +1,177 @@
+package fortio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// referenceRegex matches references like ${name} in config values, and $${
+// which stands for a literal ${
+var referenceRegex = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// hasReference reports whether s has references to resolve
+func hasReference(s string) bool {
+	return strings.Contains(s, "${")
+}
+
+// escapeReferences escapes the references in s, so s resolves to itself
+func escapeReferences(s string) string {
+	return strings.Replace(s, "${", "$${", -1)
+}
+
+// interpolator resolves references in the config values of a store:
+// ${key} is the value of another config key, ${env:NAME} the value of an env
+// variable and ${file:/path} the content of a file. ${ref:-default} falls back
+// to default if ref is not set or empty.
+type interpolator struct {
+	store     *configValues
+	resolved  map[string]interface{}
+	resolving []string
+}
+
+// interpolate resolves the references in the values of given config keys of
+// store, once all sources are merged into it
+func interpolate(store *configValues, keys []string) error {
+	in := &interpolator{store: store, resolved: map[string]interface{}{}}
+	for _, key := range keys {
+		raw := store.Get(key)
+		value, err := in.key(key)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(raw, value) {
+			store.Set(key, value)
+		}
+	}
+	return nil
+}
+
+// key returns the value of config key with its references resolved
+func (in *interpolator) key(key string) (interface{}, error) {
+	key = strings.ToLower(key)
+	if value, ok := in.resolved[key]; ok {
+		return value, nil
+	}
+	for i, k := range in.resolving {
+		if k == key {
+			cycle := append(append([]string{}, in.resolving[i:]...), key)
+			return nil, fmt.Errorf("reference cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	in.resolving = append(in.resolving, key)
+	value, err := in.value(in.store.Get(key), key)
+	in.resolving = in.resolving[:len(in.resolving)-1]
+	if err != nil {
+		return nil, err
+	}
+	in.resolved[key] = value
+	return value, nil
+}
+
+// value resolves the references in strings of value, which is a string or a
+// list or map read from a config file
+func (in *interpolator) value(value interface{}, key string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return in.string(v, key)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := in.value(item, key)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved, err := in.value(item, key)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = resolved
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+func (in *interpolator) string(s, key string) (string, error) {
+	if !hasReference(s) {
+		return s, nil
+	}
+	var err error
+	resolved := referenceRegex.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return match
+		}
+		if match == "$${" {
+			return "${"
+		}
+		var value string
+		value, err = in.reference(match[2:len(match)-1], key)
+		return value
+	})
+	return resolved, err
+}
+
+// reference returns the value of reference ref found in the value of key
+func (in *interpolator) reference(ref, key string) (string, error) {
+	name, fallback, hasFallback := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, fallback, hasFallback = ref[:i], ref[i+2:], true
+	}
+
+	var value string
+	var found bool
+	var reason string
+	switch {
+	case strings.HasPrefix(name, "env:"):
+		value, found = os.LookupEnv(strings.TrimPrefix(name, "env:"))
+	case strings.HasPrefix(name, "file:"):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(name, "file:"))
+		if err != nil {
+			reason = err.Error()
+		} else {
+			value, found = strings.TrimRight(string(data), "\r\n"), true
+		}
+	default:
+		if in.store.IsSet(name) {
+			v, err := in.key(name)
+			if err != nil {
+				return "", err
+			}
+			value, found = referenceString(v), true
+		}
+	}
+
+	if (!found || value == "") && hasFallback {
+		return fallback, nil
+	}
+	if !found {
+		if reason != "" {
+			return "", fmt.Errorf("unresolved reference ${%s} in %s - %s", ref, key, reason)
+		}
+		return "", fmt.Errorf("unresolved reference ${%s} in %s", ref, key)
+	}
+	return value, nil
+}
+
+// referenceString formats the value of a referenced config key
+func referenceString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,86 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+type InterpolatedConf struct {
+	Conf
+	Host     string   `config:"default=db.internal;usage=Host"`
+	Port     int      `config:"default=${primary.port};usage=Port"`
+	URL      string   `config:"default=postgres://${host}:${port}/app;usage=URL"`
+	Home     string   `config:"default=${env:FORTIO_TEST_INTERPOLATE_HOME};usage=Home"`
+	Token    string   `config:";usage=Token"`
+	Fallback string   `config:"default=${missing.key:-fallback} ${env:FORTIO_TEST_UNSET:-env};usage=Fallback"`
+	Literal  string   `config:"default=$${host};usage=Literal"`
+	Peers    []string `config:";usage=Peers"`
+	Primary  DBConf
+}
+
+type CycleConf struct {
+	Conf
+	A string `config:"default=${b};usage=A"`
+	B string `config:"default=x${c};usage=B"`
+	C string `config:"default=${a};usage=C"`
+}
+
+type UnresolvedConf struct {
+	Conf
+	A string `config:"default=${nope};usage=A"`
+}
+
+func TestInterpolation(t *testing.T) {
+	os.Setenv("FORTIO_TEST_INTERPOLATE_HOME", "/home/fortio")
+	defer os.Unsetenv("FORTIO_TEST_INTERPOLATE_HOME")
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	secret := writeConfigFile(t, dir, "token", "s3cr3t\n")
+	path := writeConfigFile(t, dir, "conf.yaml", "token: ${file:"+secret+"}\npeers: [\"${host}\", other]\n"+
+		"primary:\n  host: ${host}\n  port: 6543\n")
+
+	c := &InterpolatedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", path, "--host", "db.example.com"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	var testCases = []struct {
+		name, value, expected string
+	}{
+		{"URL", c.URL, "postgres://db.example.com:6543/app"},
+		{"Home", c.Home, "/home/fortio"},
+		{"Token", c.Token, "s3cr3t"},
+		{"Fallback", c.Fallback, "fallback env"},
+		{"Literal", c.Literal, "${host}"},
+		{"Primary.Host", c.Primary.Host, "db.example.com"},
+		{"Peers", strings.Join(c.Peers, ","), "db.example.com,other"},
+	}
+	for _, test := range testCases {
+		if test.value != test.expected {
+			t.Errorf("Expecting %s to be %q, got %q", test.name, test.expected, test.value)
+		}
+	}
+	if c.Port != 6543 {
+		t.Errorf("Expecting interpolated int Port to be 6543, got %d", c.Port)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(&CycleConf{}, true); err == nil || !strings.Contains(err.Error(), "cycle a -> b -> c -> a") {
+		t.Errorf("Expecting reference cycle error, got %v", err)
+	}
+
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(&UnresolvedConf{}, true); err == nil || !strings.Contains(err.Error(), "${nope} in a") {
+		t.Errorf("Expecting unresolved reference error, got %v", err)
+	}
+}
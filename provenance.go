@@ -0,0 +1,161 @@
+package fortio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Source is the kind of source that supplied the value of a config field
+type Source string
+
+// Sources of config field values, in order of precedence
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+	SourceLoader  Source = "loader"
+	SourceUnset   Source = "unset"
+)
+
+// Provenance describes where the value of a config field came from
+type Provenance struct {
+	// Field is the name of the config field
+	Field string
+	// Source is the kind of source that supplied the value
+	Source Source
+	// Flag is the name of the flag of the field
+	Flag string
+	// Env is the name of the env variable of the field, if any
+	Env string
+	// File is the config document the value was read from, a file path, URL
+	// or stdin, or the .env file of the env variable, and Line the line of
+	// the value in it when known
+	File string
+	Line int
+	// Value is the loaded value as it would be given on the command line
+	Value string
+}
+
+// Description describes the source of the value like "flag --port",
+// "env PORT" or "file /etc/myapp.yaml:3"
+func (p Provenance) Description() string {
+	switch p.Source {
+	case SourceFlag:
+		return "flag --" + p.Flag
+	case SourceEnv:
+		if p.File != "" {
+			return fmt.Sprintf("env %s from %s", p.Env, p.File)
+		}
+		return "env " + p.Env
+	case SourceFile:
+		if p.Line > 0 {
+			return fmt.Sprintf("file %s:%d", p.File, p.Line)
+		}
+		return strings.TrimSpace("file " + p.File)
+	}
+	return string(p.Source)
+}
+
+// Provenance returns where the value of each config field loaded by the last
+// Load or Reload came from, sorted by field name. It is safe to call while the
+// config is watched.
+func (cm *Manager) Provenance() []Provenance {
+	// Reload swaps the fields, store and profile of the Manager
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	provenance := make([]Provenance, 0, len(cm.fields))
+	for _, f := range cm.fields {
+		provenance = append(provenance, cm.provenance(f))
+	}
+	sort.Slice(provenance, func(i, j int) bool { return provenance[i].Field < provenance[j].Field })
+	return provenance
+}
+
+// Explain writes a table of the value of each config field and its source to w
+func (cm *Manager) Explain(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tVALUE\tSOURCE")
+	for _, p := range cm.Provenance() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Field, p.Value, p.Description())
+	}
+	return tw.Flush()
+}
+
+// provenance finds the source of the value of field following the precedence
+// of flag > env > file > default
+func (cm *Manager) provenance(f field) Provenance {
+	p := Provenance{
+		Field:  f.name,
+		Source: SourceUnset,
+		Flag:   f.key,
+		Env:    f.envName,
+		Value:  stringValue(f.addr),
+	}
+	if f.secret && p.Value != "" {
+		p.Value = redacted
+	}
+
+	if flag := cm.rootCmd.PersistentFlags().Lookup(f.key); flag != nil && flag.Changed {
+		p.Source = SourceFlag
+	} else if f.envName != "" && os.Getenv(f.envName) != "" {
+		p.Source = SourceEnv
+	} else if name := envFileName(f, cm.fields); name != "" && os.Getenv(name) != "" {
+		p.Source = SourceEnv
+		p.Env = name
+	} else if path, ok := cm.store.dotenv[f.envName]; ok && f.envName != "" {
+		p.Source = SourceEnv
+		p.File = path
+	} else if cm.store.InConfig(f.key) {
+		p.Source = SourceFile
+		o := cm.store.origins[strings.ToLower(f.key)]
+		p.File, p.Line = o.document, o.line
+	} else if f.defaultFor(cm.profile) != "" {
+		p.Source = SourceDefault
+	} else if !isZero(reflect.ValueOf(f.addr).Elem()) {
+		p.Source = SourceLoader
+	}
+	return p
+}
+
+// explainCommand returns the explain command that prints the provenance of
+// the loaded config values instead of running the app
+func (cm *Manager) explainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain",
+		Short: "Explain which source set each config value",
+		Run: func(cmd *cobra.Command, args []string) {
+			cm.commandErr = ErrExplainRequested
+		},
+	}
+}
+
+// keyLine finds the line of a possibly nested config key in a YAML, JSON or
+// TOML document by looking for each key segment after the line of its parent.
+// It returns 0 if the key is not found.
+func keyLine(data []byte, key string) int {
+	lines := strings.Split(string(data), "\n")
+	start, line := 0, 0
+	for _, segment := range strings.Split(key, ".") {
+		re := regexp.MustCompile(`(?i)^\s*(\[\s*)?["']?` + regexp.QuoteMeta(segment) + `["']?\s*(\]|:|=)`)
+		found := false
+		for i := start; i < len(lines); i++ {
+			if re.MatchString(lines[i]) {
+				start, line, found = i+1, i+1, true
+				break
+			}
+		}
+		if !found {
+			return 0
+		}
+	}
+	return line
+}
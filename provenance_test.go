@@ -0,0 +1,113 @@
+package fortio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ProvenanceConf struct {
+	Conf
+	Host  string `config:"env=FORTIO_TEST_PROVENANCE_HOST;usage=Host"`
+	Port  int    `config:"default=80;usage=Port"`
+	Unset string `config:";usage=Never set"`
+}
+
+func TestProvenance(t *testing.T) {
+	os.Setenv("FORTIO_TEST_PROVENANCE_HOST", "example.com")
+	defer os.Unsetenv("FORTIO_TEST_PROVENANCE_HOST")
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "# service config\nname: from file\n\nnumber: 5\n")
+
+	c := &ProvenanceConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--config", path, "--number", "6"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	provenance := map[string]Provenance{}
+	for _, p := range cm.Provenance() {
+		provenance[p.Field] = p
+	}
+
+	var testCases = []struct {
+		field       string
+		source      Source
+		description string
+		value       string
+	}{
+		{"Name", SourceFile, "file " + path + ":2", "from file"},
+		{"Number", SourceFlag, "flag --number", "6"},
+		{"Host", SourceEnv, "env FORTIO_TEST_PROVENANCE_HOST", "example.com"},
+		{"Port", SourceDefault, "default", "80"},
+		{"Unset", SourceUnset, "unset", ""},
+	}
+	for _, test := range testCases {
+		p := provenance[test.field]
+		if p.Source != test.source || p.Description() != test.description || p.Value != test.value {
+			t.Errorf("Expecting %s from %s (%s) = %q, got %+v", test.field, test.source, test.description, test.value, p)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := cm.Explain(buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "Host") || !strings.Contains(buf.String(), "env FORTIO_TEST_PROVENANCE_HOST") {
+		t.Errorf("Expecting explain output to list sources - %s", buf.String())
+	}
+}
+
+func TestKeyLine(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		doc  string
+		key  string
+		line int
+	}{
+		{"a: 1\nname: foo\n", "name", 2},
+		{"db:\n  user: x\n  host: y\nhost: z\n", "db.host", 3},
+		{"{\n  \"db\": {\n    \"host\": \"y\"\n  }\n}", "db.host", 3},
+		{"name = \"x\"\n\n[db]\nhost = \"y\"\n", "db.host", 4},
+		{"name: foo\n", "missing", 0},
+	}
+
+	for _, test := range testCases {
+		if line := keyLine([]byte(test.doc), test.key); line != test.line {
+			t.Errorf("Expecting %s at line %d, got %d", test.key, test.line, line)
+		}
+	}
+}
+
+func TestProvenanceWhileWatching(t *testing.T) {
+	t.Parallel()
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	if err := cm.load(&ProvenanceConf{}, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if err := cm.Watch(WatchOptions{Interval: time.Millisecond, DisableSignal: true}); err != nil {
+		t.Fatalf("Watching config not supposed to fail - %s", err.Error())
+	}
+	defer cm.StopWatch()
+
+	// run with -race to catch reads of state swapped by reloads
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		var b bytes.Buffer
+		if err := cm.Explain(&b); err != nil || !strings.Contains(b.String(), "Port") {
+			t.Fatalf("Expecting explain output while watching, got %q - %v", b.String(), err)
+		}
+	}
+}
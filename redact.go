@@ -0,0 +1,82 @@
+package fortio
+
+import (
+	"reflect"
+	"strings"
+)
+
+// redacted replaces secret values in dumps, logs, help and explain output
+const redacted = "***"
+
+// Redact returns a copy of config where fields tagged secret are replaced by
+// ***, or by their zero value if they are not strings, so it can be dumped or
+// logged safely
+//
+//	s, err := fortio.Redact(config).DumpYAML()
+//
+// Fields of type Secret are always redacted when dumped.
+func Redact(config Config) Config {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return config
+	}
+
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	fields := make(map[string]field)
+	if err := getAllFields(cp.Interface(), fields); err != nil {
+		return config
+	}
+	for _, f := range fields {
+		switch f.addr.(type) {
+		case *Secret, **Secret:
+			continue
+		}
+		if !f.secret {
+			continue
+		}
+		fv := reflect.ValueOf(f.addr).Elem()
+		if fv.Kind() == reflect.String {
+			if fv.Len() > 0 {
+				fv.SetString(redacted)
+			}
+		} else {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+	return cp.Interface().(Config)
+}
+
+// redactArgs returns command line args with the values of secret flags
+// replaced by ***
+func (cm *Manager) redactArgs(args []string) []string {
+	secrets := map[string]bool{}
+	for _, f := range cm.fields {
+		if f.secret {
+			secrets[f.key] = true
+		}
+	}
+
+	out := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		out[i] = arg
+		if redactNext {
+			out[i] = redacted
+			redactNext = false
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name := strings.TrimPrefix(arg, "--")
+		if j := strings.Index(name, "="); j >= 0 {
+			if secrets[name[:j]] {
+				out[i] = arg[:j+3] + redacted
+			}
+		} else if secrets[name] {
+			redactNext = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package fortio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+type SecretConf struct {
+	Conf
+	Password Secret     `config:"default=hunter2;usage=Password" json:"password" yaml:"password"`
+	APIKey   string     `config:"secret;usage=API key" json:"apiKey" yaml:"apiKey"`
+	Tokens   StringList `config:"secret;usage=Tokens" json:"tokens" yaml:"tokens"`
+}
+
+func (c *SecretConf) DumpJSON() (string, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c *SecretConf) DumpYAML() (string, error) {
+	b, err := yaml.Marshal(c)
+	return string(b), err
+}
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+
+	c := &SecretConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--apiKey", "key-123", "--tokens", "a,b"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Password.Value() != "hunter2" || c.APIKey != "key-123" {
+		t.Errorf("Secrets are not loaded correctly - %s %s", c.Password.Value(), c.APIKey)
+	}
+
+	j, err := Redact(c).DumpJSON()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	y, err := Redact(c).DumpYAML()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, out := range []string{j, y, fmt.Sprintf("%v %+v %#v", c.Password, c.Password, c.Password)} {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, "key-123") || !strings.Contains(out, redacted) {
+			t.Errorf("Expecting secrets to be redacted - %s", out)
+		}
+	}
+	if c.APIKey != "key-123" || len(c.Tokens) != 2 {
+		t.Errorf("Redact is not supposed to change the config")
+	}
+
+	for _, p := range cm.Provenance() {
+		if (p.Field == "Password" || p.Field == "APIKey" || p.Field == "Tokens") && p.Value != redacted {
+			t.Errorf("Expecting provenance of %s to be redacted, got %s", p.Field, p.Value)
+		}
+	}
+
+	usage := cm.rootCmd.PersistentFlags().Lookup("password").Usage
+	if strings.Contains(usage, "hunter2") {
+		t.Errorf("Expecting help default of secret to be redacted - %s", usage)
+	}
+
+	args := cm.redactArgs([]string{"app", "--apiKey", "key-123", "--tokens=a,b", "--name", "n"})
+	if strings.Join(args, " ") != "app --apiKey *** --tokens=*** --name n" {
+		t.Errorf("Expecting secret flags to be redacted, got %v", args)
+	}
+}
+
+func TestSecretMarshal(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(struct{ S Secret }{NewSecret("x")})
+	if err != nil || string(b) != `{"S":"***"}` {
+		t.Errorf("Expecting secret to marshal redacted, got %s %v", b, err)
+	}
+	b, err = json.Marshal(struct{ S Secret }{})
+	if err != nil || string(b) != `{"S":""}` {
+		t.Errorf("Expecting empty secret to marshal empty, got %s %v", b, err)
+	}
+}
@@ -0,0 +1,191 @@
+package fortio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// sampleNode is a key of a sample config file, either a field or a section
+// of nested fields
+type sampleNode struct {
+	name     string
+	field    *field
+	children []*sampleNode
+}
+
+// child returns the child node name, adding it if missing
+func (n *sampleNode) child(name string) *sampleNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &sampleNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// SampleConfig writes a sample config file for config in given format, yaml,
+// toml or json, with every field set to its default. YAML and TOML files
+// also describe each field in comments, with its usage and its env variable
+// and flag names, and list fields without a default commented out. JSON
+// files have no comments and only list fields with a default.
+func (cm *Manager) SampleConfig(w io.Writer, config Config, format string) error {
+	fields, err := cm.configFields(config)
+	if err != nil {
+		return err
+	}
+	sorted := orderedFields(fields)
+	root := &sampleNode{}
+	for i := range sorted {
+		node := root
+		for _, segment := range strings.Split(sorted[i].key, ".") {
+			node = node.child(segment)
+		}
+		node.field = &sorted[i]
+	}
+
+	switch format {
+	case "yaml", "yml":
+		writeSampleYAML(w, root, "")
+	case "toml":
+		writeSampleTOML(w, root, "")
+	case "json":
+		b, err := json.MarshalIndent(sampleJSON(root), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	default:
+		return fmt.Errorf("unsupported sample config format %q, expecting yaml, toml or json", format)
+	}
+	return nil
+}
+
+// sampleValue returns the default of f as the value of a config file, or
+// false if f has no default to show
+func sampleValue(f *field) (interface{}, bool) {
+	if f.defaultValue == "" || f.secret {
+		return nil, false
+	}
+	return schemaValue(fieldType(f.addr), f.defaultValue), true
+}
+
+// writeSampleComment writes the usage, env variable and flag of f as comments
+func writeSampleComment(w io.Writer, f *field, indent string) {
+	if f.description != "" {
+		fmt.Fprintf(w, "%s# %s\n", indent, f.description)
+	}
+	names := "flag --" + f.key
+	if f.envName != "" {
+		names = "env " + f.envName + ", " + names
+	}
+	if f.required {
+		names += ", required"
+	}
+	fmt.Fprintf(w, "%s# %s\n", indent, names)
+}
+
+func writeSampleYAML(w io.Writer, node *sampleNode, indent string) {
+	for i, c := range node.children {
+		if i > 0 && indent == "" {
+			fmt.Fprintln(w)
+		}
+		if c.field == nil {
+			fmt.Fprintf(w, "%s%s:\n", indent, c.name)
+			writeSampleYAML(w, c, indent+"  ")
+			continue
+		}
+		writeSampleComment(w, c.field, indent)
+		if value, ok := sampleValue(c.field); ok {
+			// JSON values are valid YAML
+			b, _ := json.Marshal(value)
+			fmt.Fprintf(w, "%s%s: %s\n", indent, c.name, b)
+		} else {
+			fmt.Fprintf(w, "%s# %s:\n", indent, c.name)
+		}
+	}
+}
+
+func writeSampleTOML(w io.Writer, node *sampleNode, path string) {
+	// keys of a table come before its sub tables
+	first := true
+	for _, c := range node.children {
+		if c.field == nil {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		writeSampleComment(w, c.field, "")
+		if value, ok := sampleValue(c.field); ok {
+			fmt.Fprintf(w, "%s = %s\n", c.name, tomlValue(value))
+		} else {
+			fmt.Fprintf(w, "# %s = \n", c.name)
+		}
+	}
+	for _, c := range node.children {
+		if c.field != nil {
+			continue
+		}
+		table := joinKey(path, c.name, ".")
+		fmt.Fprintf(w, "\n[%s]\n", table)
+		writeSampleTOML(w, c, table)
+	}
+}
+
+// tomlValue formats a value of a config file in TOML
+func tomlValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		var entries []string
+		for k, item := range v {
+			entries = append(entries, fmt.Sprintf("%q = %s", k, tomlValue(item)))
+		}
+		sort.Strings(entries)
+		return "{ " + strings.Join(entries, ", ") + " }"
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// sampleJSON returns the defaults below node as nested maps
+func sampleJSON(node *sampleNode) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, c := range node.children {
+		if c.field == nil {
+			if nested := sampleJSON(c); len(nested) > 0 {
+				values[c.name] = nested
+			}
+		} else if value, ok := sampleValue(c.field); ok {
+			values[c.name] = value
+		}
+	}
+	return values
+}
+
+// sampleConfigCommand returns the sample-config command that prints a sample
+// config file instead of running the app
+func (cm *Manager) sampleConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sample-config",
+		Short: "Print a sample config file with the defaults and usage of every field",
+		Run: func(cmd *cobra.Command, args []string) {
+			cm.commandErr = ErrSampleConfigRequested
+		},
+	}
+	cmd.Flags().StringVar(&cm.sampleFormat, "format", "yaml", "format of the sample config file (yaml, toml or json)")
+	return cmd
+}
@@ -0,0 +1,83 @@
+package fortio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type SampleConf struct {
+	Conf
+	Primary  DBConf
+	Ports    []int          `config:"default=80,443;usage=Ports"`
+	Limits   map[string]int `config:"default=cpu=2,memory=512;usage=Limits"`
+	Password Secret         `config:"default=hunter2;usage=Password"`
+}
+
+func TestSampleConfig(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	defaults := &SampleConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(defaults, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	for _, format := range []string{"yaml", "toml", "json"} {
+		buf := &bytes.Buffer{}
+		if err := cm.SampleConfig(buf, &SampleConf{}, format); err != nil {
+			t.Fatalf("Expecting %s sample config - %v", format, err)
+		}
+		if format != "json" && (!strings.Contains(buf.String(), "# Give me a name\n# env FORTIO_TEST_NAME, flag --name\n") ||
+			!strings.Contains(buf.String(), "# user") || strings.Contains(buf.String(), "hunter2")) {
+			t.Errorf("Expecting %s sample config to describe fields - %s", format, buf.String())
+		}
+
+		// the sample config loads the same values as the defaults
+		path := writeConfigFile(t, dir, "sample."+format, buf.String())
+		c := &SampleConf{}
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.rootCmd.SetArgs([]string{"--config", path})
+		if err := cm.load(c, true); err != nil {
+			t.Fatalf("Loading %s sample config not supposed to fail - %s\n%s", format, err.Error(), buf.String())
+		}
+		c.Password = defaults.Password
+		if !reflect.DeepEqual(c, defaults) {
+			t.Errorf("Expecting %s sample config to load defaults %+v, got %+v\n%s", format, defaults, c, buf.String())
+		}
+		for _, p := range cm.Provenance() {
+			if p.Field == "Primary.Port" && p.Source != SourceFile {
+				t.Errorf("Expecting %s sample config to set Primary.Port, got %+v", format, p)
+			}
+		}
+	}
+
+	if err := cm.SampleConfig(&bytes.Buffer{}, &SampleConf{}, "ini"); err == nil {
+		t.Errorf("Expecting error for unsupported format")
+	}
+}
+
+func TestSampleConfigCommand(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"sample-config", "--format", "toml"})
+	cm.rootCmd.SetOutput(buf)
+	if err := cm.load(&SampleConf{}, true); err != ErrSampleConfigRequested {
+		t.Errorf("Expecting ErrSampleConfigRequested, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "[primary]\n") || !strings.Contains(buf.String(), "name = \"my name\"") {
+		t.Errorf("Expecting sample-config command to print a TOML sample - %s", buf.String())
+	}
+}
@@ -0,0 +1,239 @@
+package fortio
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// schemaDraft is the JSON Schema version of the schemas written by Schema
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// durationPattern matches the durations accepted by time.ParseDuration
+const durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema returns a JSON Schema describing the config files accepted for
+// config, with the type, default, usage, required fields and constraints of
+// each field. Nested structs are described as nested objects.
+func (cm *Manager) Schema(config Config) ([]byte, error) {
+	fields := make(map[string]field)
+	if err := getAllFields(config, fields); err != nil {
+		return nil, err
+	}
+
+	root := map[string]interface{}{
+		"$schema": schemaDraft,
+		"type":    "object",
+	}
+	if cm.appName != "" {
+		root["title"] = cm.appName
+	}
+	if cm.rootCmd.Short != "" {
+		root["description"] = cm.rootCmd.Short
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f := fields[key]
+		segments := strings.Split(key, ".")
+		parent := root
+		for _, segment := range segments[:len(segments)-1] {
+			parent = schemaObject(parent, segment)
+		}
+		name := segments[len(segments)-1]
+		schemaProperties(parent)[name] = fieldSchema(f)
+		if f.required && f.defaultValue == "" {
+			required, _ := parent["required"].([]string)
+			parent["required"] = append(required, name)
+		}
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// schemaProperties returns the properties of an object schema
+func schemaProperties(object map[string]interface{}) map[string]interface{} {
+	properties, ok := object["properties"].(map[string]interface{})
+	if !ok {
+		properties = map[string]interface{}{}
+		object["properties"] = properties
+	}
+	return properties
+}
+
+// schemaObject returns the schema of the nested object name of parent
+func schemaObject(parent map[string]interface{}, name string) map[string]interface{} {
+	properties := schemaProperties(parent)
+	object, ok := properties[name].(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{"type": "object"}
+		properties[name] = object
+	}
+	return object
+}
+
+// fieldSchema describes the value of a config field
+func fieldSchema(f field) map[string]interface{} {
+	t := fieldType(f.addr)
+	schema := typeSchema(t)
+	if f.description != "" {
+		schema["description"] = f.description
+	}
+	if f.secret {
+		schema["writeOnly"] = true
+	} else if f.defaultValue != "" {
+		schema["default"] = schemaValue(t, f.defaultValue)
+	}
+
+	for _, v := range f.validations {
+		switch v.name {
+		case "oneof":
+			var enum []interface{}
+			for _, option := range strings.Split(v.arg, "|") {
+				enum = append(enum, schemaValue(t, option))
+			}
+			schema["enum"] = enum
+		case "regex":
+			if schema["type"] == "string" {
+				schema["pattern"] = v.arg
+			}
+		case "nonempty":
+			addBound(schema, "min", "1", true)
+		case "len":
+			addBound(schema, "min", v.arg, true)
+			addBound(schema, "max", v.arg, true)
+		case "min", "max":
+			addBound(schema, v.name, v.arg, false)
+		}
+	}
+	return schema
+}
+
+// addBound adds a min or max constraint to schema, which bounds the value of
+// numbers and the length of strings, arrays and objects. Only lengths are
+// bounded if lengthOnly is set.
+func addBound(schema map[string]interface{}, bound, arg string, lengthOnly bool) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		// bounds like durations can't be expressed in JSON Schema
+		return
+	}
+	keywords := map[string][2]string{
+		"integer": {"minimum", "maximum"},
+		"number":  {"minimum", "maximum"},
+		"string":  {"minLength", "maxLength"},
+		"array":   {"minItems", "maxItems"},
+		"object":  {"minProperties", "maxProperties"},
+	}
+	typ, _ := schema["type"].(string)
+	k, ok := keywords[typ]
+	if !ok || (lengthOnly && (typ == "integer" || typ == "number")) {
+		return
+	}
+	keyword := k[0]
+	if bound == "max" {
+		keyword = k[1]
+	}
+	schema[keyword] = n
+}
+
+// typeSchema describes values of type t
+func typeSchema(t reflect.Type) map[string]interface{} {
+	addr := reflect.New(t).Interface()
+	switch addr.(type) {
+	case *StringList:
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	case *MapObject:
+		return map[string]interface{}{"type": "object"}
+	case *Duration:
+		return map[string]interface{}{"type": "string", "pattern": durationPattern}
+	}
+	if _, ok := addr.(json.Unmarshaler); ok && t.Kind() == reflect.Struct {
+		// anything the type accepts as JSON
+		return map[string]interface{}{}
+	}
+	if textParser(addr) != nil {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	}
+	return map[string]interface{}{}
+}
+
+// schemaValue converts a value of a config tag, like a default, into the JSON
+// value of type t. Values that can't be converted are kept as strings.
+func schemaValue(t reflect.Type, s string) interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	case reflect.Slice, reflect.Map:
+		var items []interface{}
+		if _, ok := reflect.New(t).Interface().(*StringList); ok {
+			for _, item := range strings.Split(s, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+			return items
+		}
+		if !isCollection(reflect.New(t).Interface()) {
+			break
+		}
+		if t.Kind() == reflect.Map {
+			entries, err := mapEntries(s)
+			if err != nil {
+				break
+			}
+			object := map[string]interface{}{}
+			for k, v := range entries {
+				object[k] = schemaValue(t.Elem(), v)
+			}
+			return object
+		}
+		for _, item := range strings.Split(s, ",") {
+			items = append(items, schemaValue(t.Elem(), strings.TrimSpace(item)))
+		}
+		return items
+	}
+	return s
+}
+
+// schemaCommand returns the schema command that prints the JSON Schema of the
+// config instead of running the app
+func (cm *Manager) schemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config files",
+		Run: func(cmd *cobra.Command, args []string) {
+			cm.commandErr = ErrSchemaRequested
+		},
+	}
+}
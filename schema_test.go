@@ -0,0 +1,115 @@
+package fortio
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type SchemaConf struct {
+	Conf
+	Level    string            `config:"default=info;oneof=debug|info|warn;usage=Log level"`
+	Workers  int               `config:"required;min=1;max=16;usage=Number of workers"`
+	Owner    string            `config:"nonempty;regex=^[a-z]+$;usage=Owner"`
+	Ports    []int             `config:"default=80,443;max=4;usage=Ports"`
+	Tags     map[string]string `config:";usage=Tags"`
+	Password Secret            `config:"default=hunter2;usage=Password"`
+	Primary  DBConf
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	b, err := cm.Schema(&SchemaConf{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("Expecting schema to be JSON - %v", err)
+	}
+
+	property := func(path ...string) map[string]interface{} {
+		s := schema
+		for _, name := range path {
+			s, _ = s["properties"].(map[string]interface{})[name].(map[string]interface{})
+		}
+		return s
+	}
+
+	var testCases = []struct {
+		path     []string
+		expected map[string]interface{}
+	}{
+		{[]string{"level"}, map[string]interface{}{
+			"type": "string", "default": "info", "description": "Log level",
+			"enum": []interface{}{"debug", "info", "warn"},
+		}},
+		{[]string{"workers"}, map[string]interface{}{
+			"type": "integer", "minimum": 1.0, "maximum": 16.0, "description": "Number of workers",
+		}},
+		{[]string{"owner"}, map[string]interface{}{
+			"type": "string", "minLength": 1.0, "pattern": "^[a-z]+$", "description": "Owner",
+		}},
+		{[]string{"ports"}, map[string]interface{}{
+			"type": "array", "items": map[string]interface{}{"type": "integer"}, "maxItems": 4.0,
+			"default": []interface{}{80.0, 443.0}, "description": "Ports",
+		}},
+		{[]string{"tags"}, map[string]interface{}{
+			"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}, "description": "Tags",
+		}},
+		{[]string{"password"}, map[string]interface{}{
+			"type": "string", "writeOnly": true, "description": "Password",
+		}},
+		{[]string{"primary", "port"}, map[string]interface{}{
+			"type": "integer", "default": 5432.0, "description": "Database port",
+		}},
+	}
+	for _, test := range testCases {
+		if s := property(test.path...); !reflect.DeepEqual(s, test.expected) {
+			t.Errorf("Expecting schema of %v to be %v, got %v", test.path, test.expected, s)
+		}
+	}
+
+	if required := schema["required"]; !reflect.DeepEqual(required, []interface{}{"workers"}) {
+		t.Errorf("Expecting only workers to be required, got %v", required)
+	}
+	if schema["$schema"] != schemaDraft || schema["title"] != "fortio-test" {
+		t.Errorf("Expecting schema header, got %v %v", schema["$schema"], schema["title"])
+	}
+}
+
+func TestSchemaCommand(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"schema"})
+	cm.rootCmd.SetOutput(buf)
+	if err := cm.load(&SchemaConf{}, true); err != ErrSchemaRequested {
+		t.Errorf("Expecting ErrSchemaRequested, got %v", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil || schema["properties"] == nil {
+		t.Errorf("Expecting schema command to print the schema - %v %s", err, buf.String())
+	}
+}
+
+func TestDurationPattern(t *testing.T) {
+	t.Parallel()
+
+	re := regexp.MustCompile(durationPattern)
+	for _, d := range []string{"100ms", "1h30m", "1.5s", "0", "-2m"} {
+		if !re.MatchString(d) {
+			t.Errorf("Expecting %s to match duration pattern", d)
+		}
+	}
+	for _, d := range []string{"abc", "10", "1 s"} {
+		if re.MatchString(d) {
+			t.Errorf("Expecting %s not to match duration pattern", d)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package fortio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+)
+
+const (
+	defaultStdinMaxSize = 1 << 20
+	defaultStdinTimeout = 5 * time.Second
+)
+
+// tomlLineRegex matches a TOML table header or key = value pair
+var tomlLineRegex = regexp.MustCompile(`^(\[\[?[\w.\-"' ]+\]\]?$|[\w.\-"]+\s*=)`)
+
+// StdinConfigLoader provides autowiring of config values piped in from stdin.
+// The format of the piped config (JSON, YAML or TOML) is detected from its content.
+type StdinConfigLoader struct {
+	// MaxSize is the maximum number of bytes read from stdin, defaults to 1MB
+	MaxSize int64
+	// Timeout is the maximum time to wait for stdin to be closed, defaults to 5s
+	Timeout time.Duration
+	// Format forces the config format to one of yaml, json or toml instead
+	// of detecting it
+	Format string
+
+	// in replaces stdin, used in tests
+	in io.Reader
+	// logger is the Logger of the Manager, the package Log when used on its own
+	logger Logger
+
+	configStore
+}
+
+// Load reads config values piped to stdin and makes them available to the
+// config fields, with lower precedence than env variables and flags. Nothing
+// is read if stdin is a terminal.
+func (s *StdinConfigLoader) Load(config Config) error {
+	in := s.in
+	if in == nil {
+		stat, err := os.Stdin.Stat()
+		if err != nil {
+			return fmt.Errorf("unable to stat stdin - %v", err)
+		}
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			s.log().Warn("stdin is a terminal, no config is read from it - pipe a config document to load it")
+			return nil
+		}
+		in = os.Stdin
+	}
+
+	data, err := s.read(in)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	configType := s.Format
+	if configType == "" {
+		configType = detectConfigType(data)
+	}
+	return mergeConfig(s.values(), data, configType, "stdin")
+}
+
+func (s *StdinConfigLoader) setLogger(logger Logger) {
+	s.logger = logger
+}
+
+func (s *StdinConfigLoader) log() Logger {
+	if s.logger == nil {
+		return Log
+	}
+	return s.logger
+}
+
+// read reads all of in, failing if it exceeds MaxSize or takes longer than Timeout
+func (s *StdinConfigLoader) read(in io.Reader) ([]byte, error) {
+	maxSize := s.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultStdinMaxSize
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultStdinTimeout
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := ioutil.ReadAll(io.LimitReader(in, maxSize+1))
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("unable to read config from stdin - %v", r.err)
+		}
+		if int64(len(r.data)) > maxSize {
+			return nil, fmt.Errorf("config from stdin exceeds max size of %d bytes", maxSize)
+		}
+		return r.data, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out reading config from stdin after %v", timeout)
+	}
+}
+
+// detectConfigType guesses the config type of data. JSON starts with an object
+// or array, TOML has tables or key = value pairs and YAML is the fallback.
+func detectConfigType(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return "json"
+	}
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if tomlLineRegex.Match(line) {
+			return "toml"
+		}
+		return "yaml"
+	}
+	return "yaml"
+}
@@ -0,0 +1,134 @@
+package fortio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectConfigType(t *testing.T) {
+	t.Parallel()
+
+	var testCases = [][]string{
+		{`{"name": "foo"}`, "json"},
+		{"  \n{\n}", "json"},
+		{"name: foo\nnumber: 1", "yaml"},
+		{"# comment\n---\nname: foo", "yaml"},
+		{"name = \"foo\"", "toml"},
+		{"# comment\n\n[server]\nport = 80", "toml"},
+		{"[[servers]]\nport = 80", "toml"},
+		{"url: http://host/?a=b", "yaml"},
+	}
+
+	for _, test := range testCases {
+		out := detectConfigType([]byte(test[0]))
+		if out != test[1] {
+			t.Errorf("Expecting config type of %q to be %s, but got %s", test[0], test[1], out)
+		}
+	}
+}
+
+func TestStdinConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []string{
+		`{"name": "piped", "number": 7}`,
+		"name: piped\nnumber: 7\n",
+		"name = \"piped\"\nnumber = 7\n",
+	}
+
+	for _, test := range testCases {
+		c := &Conf{}
+		cm := NewConfigManager("fortio-test", "My Fortio test", &StdinConfigLoader{in: strings.NewReader(test)})
+		if err := cm.load(c, false); err != nil {
+			t.Fatalf("Config loading from stdin not supposed to fail - %s", err.Error())
+		}
+		if c.Name != "piped" || c.Number != 7 {
+			t.Errorf("Config is not loaded correctly from %q - %s %d", test, c.Name, c.Number)
+		}
+	}
+}
+
+func TestStdinConfigLoaderParseErrorLine(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		config string
+		format string
+		line   string
+	}{
+		{"{\n\"name\": \"piped\",\n\"number\": 7,\n}", "", "line"},
+		{"name: piped\nnumber: 7\n  bad: [\n", "", "line"},
+		{"name = \"piped\"\nnumber = 7x\n", "toml", "at line 2"},
+		{"name = \"piped\"\n\nnumber = 7\nnumber = 8\n", "toml", "at line 4"},
+	}
+
+	for _, test := range testCases {
+		loader := &StdinConfigLoader{in: strings.NewReader(test.config), Format: test.format}
+		loader.setStore(newConfigValues())
+		err := loader.Load(&Conf{})
+		if err == nil {
+			t.Fatalf("Expecting parse error for %q", test.config)
+		}
+		if !strings.Contains(err.Error(), test.line) {
+			t.Errorf("Expecting parse error to include %s - %s", test.line, err.Error())
+		}
+	}
+}
+
+func TestStdinConfigLoaderLimits(t *testing.T) {
+	t.Parallel()
+
+	loader := &StdinConfigLoader{MaxSize: 10, in: strings.NewReader("name: a very long name\n")}
+	loader.setStore(newConfigValues())
+	if err := loader.Load(&Conf{}); err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Errorf("Expecting max size error, got %v", err)
+	}
+
+	r, w := io.Pipe()
+	defer w.Close()
+	loader = &StdinConfigLoader{Timeout: 10 * time.Millisecond, in: r}
+	loader.setStore(newConfigValues())
+	if err := loader.Load(&Conf{}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expecting timeout error, got %v", err)
+	}
+}
+
+type recordingLogger struct {
+	EmptyLogger
+	debugs   []string
+	warnings []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.debugs = append(l.debugs, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warn(args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprint(args...))
+}
+
+func TestStdinConfigLoaderTerminal(t *testing.T) {
+	// a character device like a terminal
+	tty, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err.Error())
+	}
+	defer tty.Close()
+	stdin := os.Stdin
+	os.Stdin = tty
+	defer func() { os.Stdin = stdin }()
+
+	logger := &recordingLogger{}
+	cm := NewConfigManager("fortio-test", "My Fortio test", &StdinConfigLoader{})
+	cm.SetLogger(logger)
+	if err := cm.load(&Conf{}, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "stdin is a terminal") {
+		t.Errorf("Expecting warning through the Manager logger for stdin terminal, got %v", logger.warnings)
+	}
+}
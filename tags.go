@@ -0,0 +1,176 @@
+package fortio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tagKeys are the keys of the config tag, and whether they take a value
+var tagKeys = map[string]bool{
+	"default":  true,
+	"usage":    true,
+	"env":      true,
+	"url":      true,
+	"prefix":   true,
+	"merge":    true,
+	"required": false,
+	"secret":   false,
+	"nofile":   false,
+	"min":      true,
+	"max":      true,
+	"len":      true,
+	"oneof":    true,
+	"regex":    true,
+	"nonempty": false,
+}
+
+// profileDefaultPrefix starts the keys of profile defaults like default.prod
+const profileDefaultPrefix = "default."
+
+// tagEntry is a key and its value in a config tag
+type tagEntry struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parseTag parses a config tag of ; separated entries, each a key or a
+// key=value pair. Values may contain =, and are quoted with single quotes to
+// contain ; as in default='a;b'. Inside and outside of quotes \; and \' stand
+// for ; and ', any other backslash is kept as is so regexes need no escaping.
+func parseTag(tag string) ([]tagEntry, error) {
+	var entries []tagEntry
+	for pos := 0; pos < len(tag); {
+		end := pos
+		for end < len(tag) && tag[end] != ';' && tag[end] != '=' {
+			end++
+		}
+		key := strings.TrimSpace(tag[pos:end])
+		if end == len(tag) || tag[end] == ';' {
+			// key without value, or an empty entry like the leading ; of
+			// config:";usage=..."
+			if key != "" {
+				entries = append(entries, tagEntry{key: key})
+			}
+			pos = end + 1
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("missing key before = at %d", end)
+		}
+
+		value, next, err := parseTagValue(tag, end+1)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s - %v", key, err)
+		}
+		entries = append(entries, tagEntry{key: key, value: value, hasValue: true})
+		pos = next
+	}
+	return entries, nil
+}
+
+// parseTagValue parses the value starting at pos of tag and returns it with
+// the position after its entry
+func parseTagValue(tag string, pos int) (string, int, error) {
+	var value strings.Builder
+	quoted := pos < len(tag) && tag[pos] == '\''
+	if quoted {
+		pos++
+	}
+	for ; pos < len(tag); pos++ {
+		c := tag[pos]
+		switch {
+		case c == '\\' && pos+1 < len(tag) && (tag[pos+1] == ';' || tag[pos+1] == '\''):
+			pos++
+			value.WriteByte(tag[pos])
+		case quoted && c == '\'':
+			rest := tag[pos+1:]
+			if i := strings.IndexByte(rest, ';'); i >= 0 {
+				rest = rest[:i]
+			}
+			if strings.TrimSpace(rest) != "" {
+				return "", 0, fmt.Errorf("unexpected %q after quoted value", strings.TrimSpace(rest))
+			}
+			return value.String(), pos + 1 + len(rest) + 1, nil
+		case !quoted && c == ';':
+			return value.String(), pos + 1, nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	if quoted {
+		return "", 0, errors.New("missing closing quote")
+	}
+	return value.String(), pos, nil
+}
+
+// checkTagEntry returns an error if e has an unknown key, or lacks or has a
+// value when its key doesn't
+func checkTagEntry(e tagEntry) error {
+	takesValue, ok := tagKeys[e.key]
+	if strings.HasPrefix(e.key, profileDefaultPrefix) {
+		takesValue, ok = true, true
+	}
+	switch {
+	case !ok:
+		if suggestion := similarTagKey(e.key); suggestion != "" {
+			return fmt.Errorf("unknown key %q, did you mean %q?", e.key, suggestion)
+		}
+		return fmt.Errorf("unknown key %q", e.key)
+	case takesValue && !e.hasValue:
+		return fmt.Errorf("%s needs a value like %s=...", e.key, e.key)
+	case !takesValue && e.hasValue:
+		return fmt.Errorf("%s doesn't take a value", e.key)
+	}
+	return nil
+}
+
+// similarTagKey returns the known key closest to key if it is likely a typo
+func similarTagKey(key string) string {
+	var known []string
+	for k := range tagKeys {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+
+	best, bestDistance := "", 3
+	for _, k := range known {
+		if d := editDistance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
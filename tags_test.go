@@ -0,0 +1,81 @@
+package fortio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		tag     string
+		entries []tagEntry
+	}{
+		{";default=my name;usage=Give me a name", []tagEntry{{"default", "my name", true}, {"usage", "Give me a name", true}}},
+		{"default=postgres://u:p@h/db?sslmode=disable;required", []tagEntry{{"default", "postgres://u:p@h/db?sslmode=disable", true}, {"required", "", false}}},
+		{"usage='a; b = c';secret", []tagEntry{{"usage", "a; b = c", true}, {"secret", "", false}}},
+		{`usage=a\; b;default=it\'s`, []tagEntry{{"usage", "a; b", true}, {"default", "it's", true}}},
+		{`default='it\'s' ;usage=`, []tagEntry{{"default", "it's", true}, {"usage", "", true}}},
+		{`regex=^\d+$`, []tagEntry{{"regex", `^\d+$`, true}}},
+		{"", nil},
+	}
+	for _, test := range testCases {
+		entries, err := parseTag(test.tag)
+		if err != nil || !reflect.DeepEqual(entries, test.entries) {
+			t.Errorf("Expecting %s to parse to %v, got %v %v", test.tag, test.entries, entries, err)
+		}
+	}
+
+	for _, tag := range []string{"default='open", "default='a'b;usage=c", "=value"} {
+		if _, err := parseTag(tag); err == nil {
+			t.Errorf("Expecting error for malformed tag %s", tag)
+		}
+	}
+}
+
+type TypoConf struct {
+	Conf
+	Primary struct {
+		Host string `config:"defualt=localhost;usage=Host"`
+	}
+}
+
+type QuotedConf struct {
+	Conf
+	DSN   string `config:"default=postgres://u:p@h/db?sslmode=disable;usage=DSN, like a=b"`
+	Label string `config:"default='a;b';usage='Label; quoted'"`
+}
+
+func TestTagErrors(t *testing.T) {
+	t.Parallel()
+
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	err := cm.load(&TypoConf{}, true)
+	tagErr, ok := err.(*TagError)
+	if !ok || tagErr.Field != "Primary.Host" || !strings.Contains(err.Error(), `did you mean "default"`) {
+		t.Errorf("Expecting TagError suggesting default for Primary.Host, got %v", err)
+	}
+
+	for _, tag := range []string{"required=yes", "min", "usage=x;colour=red"} {
+		f := reflect.StructField{Name: "Field", Tag: reflect.StructTag(tagName + `:"` + tag + `"`)}
+		if _, err := getField(f); err == nil {
+			t.Errorf("Expecting error for tag %s", tag)
+		}
+	}
+
+	c := &QuotedConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.DSN != "postgres://u:p@h/db?sslmode=disable" || c.Label != "a;b" {
+		t.Errorf("Expecting defaults with = and ; to be loaded - %s %s", c.DSN, c.Label)
+	}
+	if usage := cm.rootCmd.PersistentFlags().Lookup("label").Usage; !strings.HasPrefix(usage, "Label; quoted") {
+		t.Errorf("Expecting quoted usage, got %s", usage)
+	}
+}
@@ -0,0 +1,48 @@
+package fortio
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+)
+
+// textFlag is the command line flag of a field whose type parses itself from
+// text, like net.IP, big.Int or any pflag.Value. It holds the value given on
+// the command line, which is parsed into the field by CmdLineConfigLoader.
+type textFlag struct {
+	value string
+	typ   string
+}
+
+func (t *textFlag) String() string { return t.value }
+
+func (t *textFlag) Set(s string) error {
+	t.value = s
+	return nil
+}
+
+func (t *textFlag) Type() string { return t.typ }
+
+// textParser returns a func parsing text into the value at addr if its type
+// implements StringParsable, encoding.TextUnmarshaler, flag.Value or
+// json.Unmarshaler, or nil otherwise
+func textParser(addr interface{}) func(string) error {
+	switch v := addr.(type) {
+	case StringParsable:
+		return v.ParseString
+	case encoding.TextUnmarshaler:
+		return func(s string) error { return v.UnmarshalText([]byte(s)) }
+	case flag.Value:
+		return v.Set
+	case json.Unmarshaler:
+		return func(s string) error {
+			// plain strings like an env variable are not JSON documents
+			if !json.Valid([]byte(s)) {
+				b, _ := json.Marshal(s)
+				return v.UnmarshalJSON(b)
+			}
+			return v.UnmarshalJSON([]byte(s))
+		}
+	}
+	return nil
+}
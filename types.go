@@ -106,3 +106,59 @@ func (jm *MapObject) Set(s string) error {
 func (jm *MapObject) Type() string {
 	return "fortio.MapObject"
 }
+
+// Secret is a string config value like a password or API key that shows up
+// as *** when printed, dumped to JSON or YAML, or shown in help and explain
+// output. Use Value to read the secret.
+type Secret struct {
+	value string
+}
+
+// NewSecret returns a Secret holding given value
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Value returns the secret value
+func (s Secret) Value() string {
+	return s.value
+}
+
+// String returns *** if the secret is set
+func (s Secret) String() string {
+	if s.value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString returns *** if the secret is set, so %#v doesn't show it either
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// Set sets the secret value given the argument from cmdline
+func (s *Secret) Set(v string) error {
+	return s.ParseString(v)
+}
+
+// ParseString sets the secret value
+func (s *Secret) ParseString(v string) error {
+	s.value = v
+	return nil
+}
+
+// Type returns type name
+func (s *Secret) Type() string {
+	return "fortio.Secret"
+}
+
+// MarshalJSON marshals the secret redacted
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// MarshalYAML marshals the secret redacted
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
@@ -0,0 +1,295 @@
+package fortio
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MissingField describes a required config field that has no value, with
+// the names it can be set by
+type MissingField struct {
+	Name string
+	Flag string
+	Env  string
+	Key  string
+}
+
+// MissingFieldsError is returned by Load when required config fields have no
+// value from any source. It lists all missing fields at once.
+type MissingFieldsError struct {
+	Fields []MissingField
+}
+
+func (e *MissingFieldsError) Error() string {
+	missing := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		sources := []string{"flag --" + f.Flag}
+		if f.Env != "" {
+			sources = append(sources, "env "+f.Env)
+		}
+		sources = append(sources, "file key "+f.Key)
+		missing = append(missing, fmt.Sprintf("%s (%s)", f.Name, strings.Join(sources, ", ")))
+	}
+	return "missing required config fields: " + strings.Join(missing, "; ")
+}
+
+// checkRequired returns a MissingFieldsError listing every required field
+// that ended up without a value
+func (cm *Manager) checkRequired() error {
+	var missing []MissingField
+	for _, f := range cm.fields {
+		if f.required && !cm.isSet(f) {
+			missing = append(missing, MissingField{
+				Name: f.name,
+				Flag: f.key,
+				Env:  f.envName,
+				Key:  f.key,
+			})
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return &MissingFieldsError{Fields: missing}
+}
+
+// isSet reports whether any source supplied a value for field, or a loader
+// set it directly in the config
+func (cm *Manager) isSet(f field) bool {
+	return cm.provenance(f).Source != SourceUnset
+}
+
+// InvalidField describes a config field whose value violates a constraint
+// of its config tag
+type InvalidField struct {
+	Name   string
+	Source string
+	Reason string
+}
+
+// InvalidFieldsError is returned by Load when config field values violate
+// the constraints of their config tags. It lists all violations at once.
+type InvalidFieldsError struct {
+	Fields []InvalidField
+}
+
+func (e *InvalidFieldsError) Error() string {
+	invalid := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		invalid = append(invalid, fmt.Sprintf("%s from %s: %s", f.Name, f.Source, f.Reason))
+	}
+	return "invalid config fields: " + strings.Join(invalid, "; ")
+}
+
+// validation is a constraint like min=1 given in the config tag of a field,
+// with its argument parsed when the tag is read
+type validation struct {
+	name  string
+	arg   string
+	limit float64
+	re    *regexp.Regexp
+}
+
+// newValidation returns the constraint name=arg of a field of type typ, or an
+// error if arg is not valid for the constraint
+func newValidation(name, arg string, typ reflect.Type) (validation, error) {
+	c := validation{name: name, arg: arg}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch name {
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return c, fmt.Errorf("invalid regex %q - %v", arg, err)
+		}
+		c.re = re
+	case "len":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return c, fmt.Errorf("invalid len %q", arg)
+		}
+		c.limit = float64(n)
+	case "min", "max":
+		if typ == reflect.TypeOf(Duration{}) {
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return c, fmt.Errorf("invalid %s duration %q", name, arg)
+			}
+			c.limit = float64(d)
+			break
+		}
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid %s %q", name, arg)
+		}
+		c.limit = limit
+	}
+	return c, nil
+}
+
+// validationTags are the config tag keys that define constraints
+var validationTags = map[string]bool{
+	"min":      true,
+	"max":      true,
+	"len":      true,
+	"oneof":    true,
+	"regex":    true,
+	"nonempty": true,
+}
+
+// checkConstraints returns a InvalidFieldsError listing every field that
+// violates a constraint of its config tag
+func (cm *Manager) checkConstraints() error {
+	var invalid []InvalidField
+	for _, f := range cm.fields {
+		for _, c := range f.validations {
+			if err := c.check(f.addr); err != nil {
+				reason := err.Error()
+				if value := stringValue(f.addr); f.secret && value != "" {
+					reason = strings.Replace(reason, value, redacted, -1)
+				}
+				source := cm.provenance(f).Description()
+				invalid = append(invalid, InvalidField{Name: f.name, Source: source, Reason: reason})
+			}
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Slice(invalid, func(i, j int) bool { return invalid[i].Name < invalid[j].Name })
+	return &InvalidFieldsError{Fields: invalid}
+}
+
+// check returns an error if the value at addr violates the constraint
+func (c validation) check(addr interface{}) error {
+	v := reflect.ValueOf(addr).Elem()
+	if v.Kind() == reflect.Ptr {
+		// constraints apply to the value of pointer fields that are set
+		if v.IsNil() {
+			if c.name == "nonempty" {
+				return errors.New("must not be empty")
+			}
+			return nil
+		}
+		addr, v = v.Interface(), v.Elem()
+	}
+	switch c.name {
+	case "nonempty":
+		if isZero(v) {
+			return errors.New("must not be empty")
+		}
+	case "oneof":
+		s := stringValue(addr)
+		for _, option := range strings.Split(c.arg, "|") {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Replace(c.arg, "|", ", ", -1))
+	case "regex":
+		if s := stringValue(addr); !c.re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, c.arg)
+		}
+	case "len":
+		n, ok := length(v)
+		if !ok {
+			return fmt.Errorf("len is not supported for %s", v.Type())
+		}
+		if float64(n) != c.limit {
+			return fmt.Errorf("length %d is not %s", n, c.arg)
+		}
+	case "min", "max":
+		return c.checkBound(addr, v)
+	}
+	return nil
+}
+
+// checkBound checks min and max constraints of numbers and durations, and
+// of the length of strings, slices and maps
+func (c validation) checkBound(addr interface{}, v reflect.Value) error {
+	var actual float64
+	var display string
+
+	if d, ok := addr.(*Duration); ok {
+		actual, display = float64(d.Duration), d.Duration.String()
+	} else {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			actual = v.Float()
+		default:
+			n, ok := length(v)
+			if !ok {
+				return fmt.Errorf("%s is not supported for %s", c.name, v.Type())
+			}
+			actual = float64(n)
+		}
+		display = fmt.Sprint(v.Interface())
+		if _, ok := length(v); ok {
+			display = fmt.Sprintf("length %d", int(actual))
+		}
+	}
+
+	if c.name == "min" && actual < c.limit {
+		return fmt.Errorf("%s is less than min %s", display, c.arg)
+	}
+	if c.name == "max" && actual > c.limit {
+		return fmt.Errorf("%s is greater than max %s", display, c.arg)
+	}
+	return nil
+}
+
+// stringValue returns the value at addr as it would be given on the command line
+func stringValue(addr interface{}) string {
+	if v := reflect.ValueOf(addr).Elem(); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		return stringValue(v.Interface())
+	}
+	if s, ok := addr.(*Secret); ok {
+		return s.Value()
+	}
+	if s, ok := addr.(fmt.Stringer); ok {
+		return s.String()
+	}
+	if t, ok := addr.(encoding.TextMarshaler); ok {
+		if b, err := t.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	if isCollection(addr) {
+		return formatCollection(reflect.ValueOf(addr).Elem())
+	}
+	return fmt.Sprint(reflect.ValueOf(addr).Elem().Interface())
+}
+
+// length returns the length of strings, slices and maps
+func length(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+func isZero(v reflect.Value) bool {
+	if n, ok := length(v); ok {
+		return n == 0
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
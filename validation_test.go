@@ -0,0 +1,132 @@
+package fortio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ValidatedConf struct {
+	Conf
+	Workers  int        `config:"default=4;min=1;max=16;usage=Number of workers"`
+	Level    string     `config:"default=info;oneof=debug|info|warn;usage=Log level"`
+	Region   string     `config:"default=us;regex=^[a-z]+$;usage=Region"`
+	Code     string     `config:"default=abc;len=3;usage=Code"`
+	Owner    string     `config:"nonempty;usage=Owner"`
+	Timeout  Duration   `config:"default=50ms;max=100ms;usage=Timeout"`
+	Backends StringList `config:"default=a,b;min=1;max=2;usage=Backends"`
+}
+
+func TestValidationConstraints(t *testing.T) {
+	t.Parallel()
+
+	c := &ValidatedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{"--owner", "team"})
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail for valid values - %s", err.Error())
+	}
+
+	c = &ValidatedConf{}
+	cm = NewConfigManager("fortio-test", "My Fortio test")
+	cm.rootCmd.SetArgs([]string{
+		"--workers", "0", "--level", "trace", "--region", "US1", "--code", "abcd",
+		"--timeout", "1s", "--backends", "a,b,c",
+	})
+	err := cm.load(c, true)
+	if err == nil {
+		t.Fatalf("Expecting error for values violating constraints")
+	}
+
+	invalid, ok := err.(*InvalidFieldsError)
+	if !ok {
+		t.Fatalf("Expecting InvalidFieldsError, got %T - %v", err, err)
+	}
+	var names []string
+	for _, f := range invalid.Fields {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "Backends,Code,Level,Owner,Region,Timeout,Workers" {
+		t.Errorf("Expecting every invalid field to be reported, got %v", names)
+	}
+	for _, f := range invalid.Fields {
+		if f.Name == "Workers" && (f.Source != "flag --workers" || !strings.Contains(f.Reason, "less than min 1")) {
+			t.Errorf("Expecting Workers error to name flag source and min, got %+v", f)
+		}
+		if f.Name == "Owner" && f.Source != "unset" {
+			t.Errorf("Expecting Owner error to have no source, got %+v", f)
+		}
+	}
+}
+
+func TestValidationCheck(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		name  string
+		arg   string
+		addr  interface{}
+		valid bool
+	}{
+		{"min", "1.5", &[]float64{2}[0], true},
+		{"min", "1.5", &[]float64{1}[0], false},
+		{"max", "10", &[]uint{11}[0], false},
+		{"max", "3", &[]string{"abcd"}[0], false},
+		{"min", "1s", &Duration{time.Second}, true},
+		{"oneof", "1|2", &[]int{2}[0], true},
+		{"regex", "^a+$", &[]string{"ab"}[0], false},
+		{"nonempty", "", &StringList{}, false},
+		{"len", "2", &StringList{"a", "b"}, true},
+		{"len", "2", &[]bool{true}[0], false},
+	}
+
+	for _, test := range testCases {
+		v, err := newValidation(test.name, test.arg, reflect.TypeOf(test.addr).Elem())
+		if err != nil {
+			t.Fatalf("Expecting %s=%s to be a valid constraint, got %v", test.name, test.arg, err)
+		}
+		err = v.check(test.addr)
+		if (err == nil) != test.valid {
+			t.Errorf("Expecting %s=%s valid=%v for %v, got %v", test.name, test.arg, test.valid, test.addr, err)
+		}
+	}
+}
+
+func TestValidationTagErrors(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		config Config
+		err    string
+	}{
+		{&struct {
+			Conf
+			Workers int `config:"min=abc"`
+		}{}, `invalid min "abc"`},
+		{&struct {
+			Conf
+			Workers *int `config:"max=abc"`
+		}{}, `invalid max "abc"`},
+		{&struct {
+			Conf
+			Timeout Duration `config:"max=100"`
+		}{}, `invalid max duration "100"`},
+		{&struct {
+			Conf
+			Code string `config:"len=x"`
+		}{}, `invalid len "x"`},
+		{&struct {
+			Conf
+			Region string `config:"regex=["`
+		}{}, `invalid regex "["`},
+	}
+	for _, test := range testCases {
+		cm := NewConfigManager("fortio-test", "My Fortio test")
+		cm.SetLogger(EmptyLogger{})
+		err := cm.load(test.config, false)
+		if _, ok := err.(*TagError); !ok || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expecting TagError %q, got %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package fortio
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileEventDelay is how long Watch waits for more file events before reloading,
+// editors and orchestrators often write a config file in several steps
+const fileEventDelay = 100 * time.Millisecond
+
+// WatchOptions selects the events that make Watch reload the config
+type WatchOptions struct {
+	// Interval reloads the config periodically when greater than 0
+	Interval time.Duration
+	// DisableSignal stops reloading the config on SIGHUP
+	DisableSignal bool
+	// DisableFiles stops reloading the config when a config file of a
+	// FileConfigLoader or a file in the directory of a DirectoryConfigLoader
+	// or ConfDirConfigLoader changes
+	DisableFiles bool
+}
+
+// OnChange registers a callback that is called with the previous and the new
+// config after every successful reload
+func (cm *Manager) OnChange(callback func(old, new Config)) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.onChange = append(cm.onChange, callback)
+}
+
+// Current returns the last successfully loaded config. After a reload it is a
+// different value than the config passed to Load.
+func (cm *Manager) Current() Config {
+	config, _ := cm.current.Load().(Config)
+	return config
+}
+
+// Reload runs all config loaders again into a new config, validates it and
+// swaps it in as the current config. An invalid config is rejected and the
+// current config is kept.
+func (cm *Manager) Reload() error {
+	cm.mu.Lock()
+	old := cm.Current()
+	if old == nil {
+		cm.mu.Unlock()
+		return errors.New("config must be loaded before it can be reloaded")
+	}
+	config := reflect.New(reflect.TypeOf(old).Elem()).Interface().(Config)
+
+	prevStore, prevFields, prevURLLoaders := cm.store, cm.fields, cm.urlLoaders
+	cm.store = newConfigValues()
+	withStore(cm.store, cm.configLoaders)
+
+	if err := cm.reloadInto(config); err != nil {
+		cm.store, cm.fields, cm.urlLoaders = prevStore, prevFields, prevURLLoaders
+		withStore(cm.store, cm.configLoaders)
+		cm.mu.Unlock()
+		return err
+	}
+
+	cm.current.Store(config)
+	callbacks := append([]func(old, new Config){}, cm.onChange...)
+	cm.mu.Unlock()
+
+	// callbacks are called without holding the lock so they can use the Manager
+	for _, callback := range callbacks {
+		callback(old, config)
+	}
+	return nil
+}
+
+func (cm *Manager) reloadInto(config Config) error {
+	if err := cm.createCommandLineFlags(cm.rootCmd, config); err != nil {
+		return err
+	}
+	cm.applyProfile()
+	if err := cm.loadEnvFiles(); err != nil {
+		return err
+	}
+	if err := cm.runLoaders(config); err != nil {
+		return err
+	}
+	if err := cm.checkRequired(); err != nil {
+		return err
+	}
+	if err := cm.checkConstraints(); err != nil {
+		return err
+	}
+	return config.Validate()
+}
+
+// Watch reloads the config in the background when a config file changes, on
+// SIGHUP or periodically, depending on options. Rejected reloads are logged
+// and keep the current config. Load must be called before Watch.
+func (cm *Manager) Watch(options WatchOptions) error {
+	if cm.Current() == nil {
+		return errors.New("config must be loaded before it can be watched")
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.watching != nil {
+		return errors.New("config is already watched")
+	}
+
+	var watcher *fsnotify.Watcher
+	files := map[string]bool{}
+	configDirs := map[string]bool{}
+	if !options.DisableFiles {
+		for _, path := range cm.configFiles() {
+			files[filepath.Clean(path)] = true
+		}
+		for _, path := range cm.configDirs() {
+			configDirs[filepath.Clean(path)] = true
+		}
+	}
+	if len(files) > 0 || len(configDirs) > 0 {
+		var err error
+		watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		// watch directories, files replaced by rename are not seen otherwise
+		dirs := map[string]bool{}
+		for path := range files {
+			dirs[filepath.Dir(path)] = true
+		}
+		for path := range configDirs {
+			dirs[path] = true
+		}
+		for dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				watcher.Close()
+				return err
+			}
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	if !options.DisableSignal {
+		signal.Notify(signals, syscall.SIGHUP)
+	}
+
+	cm.watching = make(chan struct{})
+	go cm.watch(cm.watching, watcher, files, configDirs, signals, options.Interval)
+	return nil
+}
+
+// StopWatch stops reloading the config started by Watch
+func (cm *Manager) StopWatch() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.watching != nil {
+		close(cm.watching)
+		cm.watching = nil
+	}
+}
+
+func (cm *Manager) watch(stop chan struct{}, watcher *fsnotify.Watcher, files, configDirs map[string]bool,
+	signals chan os.Signal, interval time.Duration) {
+	defer signal.Stop(signals)
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var fileEvents chan fsnotify.Event
+	var fileErrors chan error
+	if watcher != nil {
+		defer watcher.Close()
+		fileEvents, fileErrors = watcher.Events, watcher.Errors
+	}
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case event := <-fileEvents:
+			// any change in a config directory counts, like the swap of the
+			// ..data symlink of a ConfigMap
+			path := filepath.Clean(event.Name)
+			if files[path] || configDirs[filepath.Dir(path)] {
+				delay = time.After(fileEventDelay)
+			}
+		case err := <-fileErrors:
+			cm.logger.Warnf("Error watching config files - %v", err)
+		case <-delay:
+			delay = nil
+			cm.reload("config file change")
+		case <-signals:
+			cm.reload("SIGHUP")
+		case <-ticks:
+			cm.reload("interval")
+		}
+	}
+}
+
+func (cm *Manager) reload(reason string) {
+	if err := cm.Reload(); err != nil {
+		cm.logger.Errorf("Rejected config reload on %s, keeping previous config - %v", reason, err)
+		return
+	}
+	cm.logger.Infof("Reloaded config on %s", reason)
+}
+
+// configFiles returns the paths of all files read by FileConfigLoaders
+func (cm *Manager) configFiles() []string {
+	var paths []string
+	for _, loader := range cm.configLoaders {
+		if f, ok := loader.(*FileConfigLoader); ok {
+			paths = append(paths, f.Paths...)
+		}
+	}
+	paths = append(paths, cm.store.GetStringSlice(configFlag)...)
+	if profile := cm.store.GetString(profileFlag); profile != "" {
+		// profile files are watched even if they don't exist yet
+		for _, path := range paths {
+			paths = append(paths, profilePath(path, profile))
+		}
+	}
+	return paths
+}
+
+// configDirs returns the directories read by DirectoryConfigLoaders and
+// ConfDirConfigLoaders, drop-in directories only if they exist
+func (cm *Manager) configDirs() []string {
+	var dirs []string
+	for _, loader := range cm.configLoaders {
+		switch l := loader.(type) {
+		case *DirectoryConfigLoader:
+			dirs = append(dirs, l.Path)
+		case *ConfDirConfigLoader:
+			if fileExists(l.Path) {
+				dirs = append(dirs, l.Path)
+			}
+		}
+	}
+	return dirs
+}
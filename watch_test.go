@@ -0,0 +1,178 @@
+package fortio
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "owner: team\nworkers: 2\n")
+
+	c := &ValidatedConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test", &FileConfigLoader{Paths: []string{path}})
+	if err := cm.Reload(); err == nil {
+		t.Errorf("Expecting error when reloading before loading")
+	}
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	var changes [][2]Config
+	cm.OnChange(func(old, new Config) {
+		changes = append(changes, [2]Config{old, new})
+	})
+
+	writeConfigFile(t, dir, "conf.yaml", "owner: team\nworkers: 8\n")
+	if err := cm.Reload(); err != nil {
+		t.Fatalf("Config reloading not supposed to fail - %s", err.Error())
+	}
+	current := cm.Current().(*ValidatedConf)
+	if current.Workers != 8 || current.Owner != "team" || current.Level != "info" {
+		t.Errorf("Config is not reloaded correctly - %+v", current)
+	}
+	if c.Workers != 2 {
+		t.Errorf("Config passed to Load is not supposed to change - %d", c.Workers)
+	}
+	if len(changes) != 1 || changes[0][0] != Config(c) || changes[0][1] != Config(current) {
+		t.Errorf("Expecting OnChange to be called with old and new config, got %v", changes)
+	}
+
+	writeConfigFile(t, dir, "conf.yaml", "owner: team\nworkers: 100\n")
+	if err := cm.Reload(); err == nil {
+		t.Errorf("Expecting invalid config to be rejected")
+	}
+	if cm.Current() != Config(current) || len(changes) != 1 {
+		t.Errorf("Expecting previous config to be kept after rejected reload")
+	}
+
+	// manager keeps working with the previous values after a rejected reload
+	writeConfigFile(t, dir, "conf.yaml", "owner: other\nworkers: 3\n")
+	if err := cm.Reload(); err != nil {
+		t.Fatalf("Config reloading not supposed to fail - %s", err.Error())
+	}
+	if current := cm.Current().(*ValidatedConf); current.Workers != 3 || current.Owner != "other" {
+		t.Errorf("Config is not reloaded correctly after rejected reload - %+v", current)
+	}
+}
+
+type ReloadConf struct {
+	Conf
+	Wait  Duration   `config:"usage=Wait"`
+	Items StringList `config:"usage=Items"`
+}
+
+func TestReloadRemovedKey(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "wait: 5s\nitems: [a, b]\n")
+
+	c := &ReloadConf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test", &FileConfigLoader{Paths: []string{path}})
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+	if c.Wait.Duration != 5*time.Second || len(c.Items) != 2 {
+		t.Errorf("Config is not loaded correctly - %v %v", c.Wait, c.Items)
+	}
+
+	writeConfigFile(t, dir, "conf.yaml", "name: other\n")
+	if err := cm.Reload(); err != nil {
+		t.Fatalf("Config reloading not supposed to fail - %s", err.Error())
+	}
+	current := cm.Current().(*ReloadConf)
+	if current.Wait.Duration != 0 || len(current.Items) != 0 || current.Name != "other" {
+		t.Errorf("Expecting keys removed from the file to be unset after reload, got %v %v", current.Wait, current.Items)
+	}
+	for _, p := range cm.Provenance() {
+		if (p.Field == "Wait" || p.Field == "Items") && p.Source != SourceUnset {
+			t.Errorf("Expecting %s to be unset after reload, got %s", p.Field, p.Description())
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "fortio")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "conf.yaml", "name: before\n")
+
+	c := &Conf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	cm.rootCmd.SetArgs([]string{"--config", path})
+	if err := cm.Watch(WatchOptions{}); err == nil {
+		t.Errorf("Expecting error when watching before loading")
+	}
+	if err := cm.load(c, true); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	changed := make(chan Config, 10)
+	cm.OnChange(func(old, new Config) {
+		changed <- new
+	})
+	if err := cm.Watch(WatchOptions{DisableSignal: true}); err != nil {
+		t.Fatalf("Watching config not supposed to fail - %s", err.Error())
+	}
+	defer cm.StopWatch()
+
+	writeConfigFile(t, dir, "conf.yaml", "name: after\n")
+	select {
+	case config := <-changed:
+		if config.(*Conf).Name != "after" {
+			t.Errorf("Config is not reloaded on file change - %s", config.(*Conf).Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Config is not reloaded on file change")
+	}
+}
+
+func TestWatchInterval(t *testing.T) {
+	t.Parallel()
+
+	c := &Conf{}
+	cm := NewConfigManager("fortio-test", "My Fortio test")
+	cm.SetLogger(EmptyLogger{})
+	if err := cm.load(c, false); err != nil {
+		t.Fatalf("Config loading not supposed to fail - %s", err.Error())
+	}
+
+	changed := make(chan Config, 10)
+	cm.OnChange(func(old, new Config) {
+		changed <- new
+	})
+	if err := cm.Watch(WatchOptions{Interval: 10 * time.Millisecond, DisableSignal: true}); err != nil {
+		t.Fatalf("Watching config not supposed to fail - %s", err.Error())
+	}
+	if err := cm.Watch(WatchOptions{}); err == nil {
+		t.Errorf("Expecting error when watching twice")
+	}
+	defer cm.StopWatch()
+
+	select {
+	case config := <-changed:
+		if config.(*Conf).Name != "my name" {
+			t.Errorf("Config is not reloaded correctly on interval - %s", config.(*Conf).Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Config is not reloaded on interval")
+	}
+}